@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
 	"monkey/token"
 	"strings"
 )
@@ -142,6 +143,14 @@ func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
+type Null struct {
+	Token token.Token
+}
+
+func (n *Null) expressionNode()      {}
+func (n *Null) TokenLiteral() string { return n.Token.Literal }
+func (n *Null) String() string       { return n.Token.Literal }
+
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
@@ -220,6 +229,7 @@ type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Name       string // the name of the let binding, if any, so the function can refer to itself
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -233,6 +243,9 @@ func (fl *FunctionLiteral) String() string {
 	}
 
 	out.WriteString(fl.TokenLiteral())
+	if fl.Name != "" {
+		out.WriteString(fmt.Sprintf("<%s>", fl.Name))
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
@@ -245,6 +258,9 @@ type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	// Tail is set for calls whose value the enclosing function returns,
+	// see MarkTailCalls.
+	Tail bool
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -274,6 +290,32 @@ func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// InterpolatedString is a string literal with embedded ${...} expressions.
+// Parts holds the literal text, as *StringLiteral, and the embedded
+// expressions in source order.
+type InterpolatedString struct {
+	Token token.Token // the token.INTERP_HEAD token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, p := range is.Parts {
+		if sl, ok := p.(*StringLiteral); ok {
+			out.WriteString(sl.Value)
+			continue
+		}
+		out.WriteString("${")
+		out.WriteString(p.String())
+		out.WriteString("}")
+	}
+
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
@@ -297,9 +339,10 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // The [ token
-	Left  Expression
-	Index Expression
+	Token    token.Token // The [ or ?[ token
+	Left     Expression
+	Index    Expression
+	Optional bool // ?[ evaluates to null instead of failing when Left is null
 }
 
 func (ie *IndexExpression) expressionNode()      {}
@@ -309,6 +352,9 @@ func (ie *IndexExpression) String() string {
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -316,9 +362,42 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression is left[start:end]. Start and End are nil when omitted.
+type SliceExpression struct {
+	Token    token.Token // The [ or ?[ token
+	Left     Expression
+	Start    Expression
+	End      Expression
+	Optional bool // ?[ evaluates to null instead of failing when Left is null
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	if se.Optional {
+		out.WriteString("?")
+	}
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 type HashLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression // the keys of Pairs in source order
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -327,8 +406,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -337,3 +416,49 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// MarkTailCalls sets Tail on the calls in the body of fn whose value fn
+// returns as it is: the operands of return statements and the value of
+// the body, looking through if expressions. Engines can run such calls
+// without growing the call stack. Nested function literals are not
+// visited.
+func MarkTailCalls(fn *FunctionLiteral) {
+	if fn.Body != nil {
+		markTailCalls(fn.Body, true)
+	}
+}
+
+// markTailCalls marks the return statements of block and, if the value of
+// block is returned, its last expression.
+func markTailCalls(block *BlockStatement, returned bool) {
+	for i, stmt := range block.Statements {
+		switch stmt := stmt.(type) {
+		case *ReturnStatement:
+			markTailExpression(stmt.ReturnValue)
+		case *ExpressionStatement:
+			if returned && i == len(block.Statements)-1 {
+				markTailExpression(stmt.Expression)
+			} else if ie, ok := stmt.Expression.(*IfExpression); ok {
+				markIfExpression(ie, false)
+			}
+		}
+	}
+}
+
+func markTailExpression(exp Expression) {
+	switch exp := exp.(type) {
+	case *CallExpression:
+		exp.Tail = true
+	case *IfExpression:
+		markIfExpression(exp, true)
+	}
+}
+
+func markIfExpression(ie *IfExpression, returned bool) {
+	if ie.Consequence != nil {
+		markTailCalls(ie.Consequence, returned)
+	}
+	if ie.Alternative != nil {
+		markTailCalls(ie.Alternative, returned)
+	}
+}
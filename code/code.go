@@ -20,6 +20,31 @@ const (
 	OpEqual
 	OpNotEqual
 	OpGreaterThan // OpLessThan을 추가하지 않는 이유는, 3 < 5은 5 > 3로 바꿔서 사용할 수 있기 때문이다.
+	OpGetGlobal
+	OpSetGlobal
+	OpInterpolate // 피연산자 개수만큼 스택에서 꺼내 하나의 문자열로 이어 붙인다.
+	OpMinus
+	OpBang
+	OpJumpNotTruthy
+	OpJump
+	OpNull
+	OpJumpNotNull // ?? 연산자용. 스택 최상단이 null이 아니면 그대로 두고 점프, null이면 꺼낸다.
+	OpArray
+	OpHash
+	OpIndex
+	OpSafeIndex // ?[ 연산자용. 인덱싱 대상이 null이면 null을 결과로 한다.
+	OpSlice     // 스택의 대상, 시작, 끝을 꺼내 잘라낸 결과를 넣는다. 생략된 범위는 null로 전달한다.
+	OpSafeSlice
+	OpCall        // 피연산자는 인자의 개수. 스택에는 호출할 함수, 인자 순서로 쌓여있다.
+	OpReturnValue // 스택 최상단의 값을 반환한다.
+	OpReturn      // 반환할 값이 없을 때 null을 반환한다.
+	OpGetLocal
+	OpSetLocal
+	OpGetBuiltin
+	OpClosure // 피연산자는 상수 풀의 *object.CompiledFunction 위치와 자유 변수의 개수
+	OpGetFree
+	OpCurrentClosure // 실행 중인 클로저 자신을 스택에 넣는다. 재귀 호출용.
+	OpTailCall       // 꼬리 위치의 OpCall. 현재 프레임을 호출할 함수의 프레임으로 재사용한다.
 )
 
 type Definition struct {
@@ -39,6 +64,34 @@ var definition = map[Opcode]*Definition{
 	OpEqual:       {"OpEqual", []int{}},
 	OpNotEqual:    {"OpNotEqual", []int{}},
 	OpGreaterThan: {"OpGreaterThan", []int{}},
+	OpGetGlobal:   {"OpGetGlobal", []int{2}},
+	OpSetGlobal:   {"OpSetGlobal", []int{2}},
+	OpInterpolate: {"OpInterpolate", []int{2}},
+	OpMinus:       {"OpMinus", []int{}},
+	OpBang:        {"OpBang", []int{}},
+	// 피연산자는 점프할 명령어의 위치(offset)
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJump:          {"OpJump", []int{2}},
+	OpNull:          {"OpNull", []int{}},
+	OpJumpNotNull:   {"OpJumpNotNull", []int{2}},
+	// 피연산자는 스택에서 꺼낼 요소의 개수
+	OpArray:     {"OpArray", []int{2}},
+	OpHash:      {"OpHash", []int{2}},
+	OpIndex:     {"OpIndex", []int{}},
+	OpSafeIndex: {"OpSafeIndex", []int{}},
+	OpSlice:     {"OpSlice", []int{}},
+	OpSafeSlice: {"OpSafeSlice", []int{}},
+	// 지역 바인딩과 인자는 프레임마다 따로 있으므로 1바이트(256개)면 충분하다.
+	OpCall:           {"OpCall", []int{1}},
+	OpReturnValue:    {"OpReturnValue", []int{}},
+	OpReturn:         {"OpReturn", []int{}},
+	OpGetLocal:       {"OpGetLocal", []int{1}},
+	OpSetLocal:       {"OpSetLocal", []int{1}},
+	OpGetBuiltin:     {"OpGetBuiltin", []int{1}},
+	OpClosure:        {"OpClosure", []int{2, 1}},
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpTailCall:       {"OpTailCall", []int{1}},
 }
 
 type Instructions []byte
@@ -55,6 +108,8 @@ func (instructions Instructions) fmtInstruction(def *Definition, operands []int)
 		return def.Name
 	case 1:
 		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
 	}
 
 	return fmt.Sprintf("ERROR: unhandled operandCount for%s\n", def.Name)
@@ -92,6 +147,8 @@ func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
 		switch width {
 		case 2:
 			operands[i] = int(ReadUnit16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
 		}
 
 		offset += width
@@ -104,6 +161,10 @@ func ReadUnit16(ins Instructions) uint16 {
 	return binary.BigEndian.Uint16(ins)
 }
 
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
 func Lookup(op byte) (*Definition, error) {
 	def, ok := definition[Opcode(op)]
 
@@ -137,6 +198,8 @@ func Make(op Opcode, operands ...int) []byte {
 		switch width {
 		case 2:
 			binary.BigEndian.PutUint16(instruction[offset:], uint16(operand))
+		case 1:
+			instruction[offset] = byte(operand)
 		}
 
 		offset += width
@@ -18,6 +18,16 @@ func TestMake(t *testing.T) {
 			[]int{},
 			[]byte{byte(OpAdd)},
 		},
+		{
+			OpGetLocal,
+			[]int{255},
+			[]byte{byte(OpGetLocal), 255},
+		},
+		{
+			OpClosure,
+			[]int{65534, 255},
+			[]byte{byte(OpClosure), 255, 254, 255},
+		},
 	}
 
 	for _, tt := range tests {
@@ -67,6 +77,30 @@ func TestInstructionString(t *testing.T) {
 `
 }
 
+func TestFunctionInstructionString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpGetLocal, 1),
+		Make(OpCall, 2),
+		Make(OpClosure, 65535, 255),
+		Make(OpReturnValue),
+	}
+
+	expected := `0000 OpGetLocal 1
+0002 OpCall 2
+0004 OpClosure 65535 255
+0008 OpReturnValue
+`
+	concatted := Instructions{}
+
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted. \nwant=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
 func TestOpAddInstructionString(t *testing.T) {
 	instructions := []Instructions{
 		Make(OpAdd),
@@ -95,6 +129,8 @@ func TestReadOperands(t *testing.T) {
 		bytesRead int
 	}{
 		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
 	}
 
 	for _, tt := range tests {
@@ -2,116 +2,652 @@ package compiler
 
 import (
 	"fmt"
+	"io"
 	"monkey/ast"
 	"monkey/code"
+	"monkey/ir"
 	"monkey/object"
 )
 
+/*
+CompilationScope - 함수 본문은 별도의 ir.Function으로 만들어진다.
+함수 리터럴을 만날 때마다 새 스코프에 들어가고, 본문을 다 만들면 빠져나온다.
+block은 지금 값을 추가하고 있는 블록이다.
+*/
+type CompilationScope struct {
+	function *ir.Function
+	block    *ir.Block
+}
+
+/*
+Compiler - AST를 먼저 ir.Function으로 만들고(build), 그것을 명령어로 바꾼다(lower).
+최적화는 그 사이의 IR과 만들어진 명령어에서 한다.
+*/
 type Compiler struct {
-	// 생성한 바이트코드 담기
-	instructions code.Instructions
 	// constants pool 역할
 	constants []object.Object
+	// let으로 바인딩된 식별자와 저장소 인덱스
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// 지금까지 컴파일한 main 프로그램의 명령어
+	instructions code.Instructions
+
+	// WithOptimizations로 켠다.
+	optimize bool
+	// 최적화할 때 상수 풀에 있는 정수, 문자열 상수의 위치
+	constantIndexes map[object.HashKey]int
+
+	warnings []Warning
+
+	// WithIRDump로 설정한다.
+	irDump io.Writer
 }
 
-func New() *Compiler {
-	return &Compiler{
-		instructions: code.Instructions{},
+/*
+Option - 컴파일러 설정. New와 NewWithState에 넘긴다.
+*/
+type Option func(*Compiler)
+
+/*
+WithIRDump - 컴파일한 프로그램마다 최적화까지 마친 IR을 텍스트로 w에 쓴다. 디버깅용
+*/
+func WithIRDump(w io.Writer) Option {
+	return func(c *Compiler) {
+		c.irDump = w
+	}
+}
+
+func New(opts ...Option) *Compiler {
+	main := ir.NewFunction("main", 0)
+	mainScope := CompilationScope{
+		function: main,
+		block:    main.NewBlock(),
+	}
+
+	symbolTable := NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	c := &Compiler{
 		constants:    []object.Object{},
+		symbolTable:  symbolTable,
+		scopes:       []CompilationScope{mainScope},
+		scopeIndex:   0,
+		instructions: code.Instructions{},
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
 }
 
+/*
+NewWithState - REPL처럼 여러 번 컴파일할 때 이전 입력에서 정의한 전역 바인딩과 상수를 이어서 사용하기 위함
+*/
+func NewWithState(s *SymbolTable, constants []object.Object, opts ...Option) *Compiler {
+	compiler := New(opts...)
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+/*
+Compile - node를 main 프로그램의 IR로 만들고, 최적화한 뒤 명령어로 바꿔 지금까지의 명령어 뒤에 붙인다.
+*/
 func (c *Compiler) Compile(node ast.Node) error {
+	main := c.scopes[0].function
+
+	var err error
 	switch node := node.(type) {
+	case ast.Statement:
+		err = c.compileStatement(node)
+	case ast.Expression:
+		_, err = c.compileExpression(node)
 	case *ast.Program:
-		// 모든 node.Statements를 순회하며 c.Compile을 재귀 호출
-		for _, statement := range node.Statements {
-			err := c.Compile(statement)
+		// 모든 node.Statements를 순회하며 c.compileStatement를 재귀 호출
+		for _, statement := range c.reachableStatements(node.Statements) {
+			err = c.compileStatement(statement)
 			if err != nil {
-				return err
+				break
 			}
 		}
+	}
+	// 다음 Compile은 실패했더라도 새 IR에서 시작한다.
+	defer c.resetMain()
+	if err != nil {
+		return err
+	}
+
+	if c.optimize {
+		ir.FoldConstants(main)
+	}
+	if c.irDump != nil {
+		fmt.Fprint(c.irDump, main)
+	}
+	c.instructions = append(c.instructions, c.lower(main, len(c.instructions))...)
+
+	return nil
+}
+
+/*
+resetMain - main 프로그램의 IR을 새 함수로 바꾼다.
+함수 리터럴 안에서 실패했다면 열려 있던 스코프와 심볼 테이블도 전역으로 되돌린다.
+*/
+func (c *Compiler) resetMain() {
+	for c.scopeIndex > 0 {
+		c.leaveScope()
+	}
+
+	main := ir.NewFunction("main", 0)
+	c.scopes[0] = CompilationScope{function: main, block: main.NewBlock()}
+}
+
+func (c *Compiler) compileStatement(node ast.Statement) error {
+	switch node := node.(type) {
 	case *ast.ExpressionStatement:
 		// 1 + 2를 표현하는 노드
-		err := c.Compile(node.Expression)
+		value, err := c.compileExpression(node.Expression)
+		if err != nil {
+			return err
+		}
+		c.emit(ir.OpPop, value)
+	case *ast.BlockStatement:
+		for _, statement := range c.reachableStatements(node.Statements) {
+			err := c.compileStatement(statement)
+			if err != nil {
+				return err
+			}
+		}
+	case *ast.LetStatement:
+		value, err := c.compileExpression(node.Value)
+		if err != nil {
+			return err
+		}
+		symbol := c.symbolTable.Define(node.Name.Value)
+		store := c.emit(ir.OpStore, value)
+		store.Var = irVar(symbol)
+	case *ast.ReturnStatement:
+		value, err := c.compileExpression(node.ReturnValue)
 		if err != nil {
 			return err
 		}
-		c.emit(code.OpPop)
+		c.terminate(ir.Term{Kind: ir.Return, Value: value})
+	}
+
+	return nil
+}
+
+/*
+compileExpression - 표현식의 값을 만들고 돌려준다.
+*/
+func (c *Compiler) compileExpression(node ast.Expression) (*ir.Value, error) {
+	switch node := node.(type) {
+	case *ast.PrefixExpression:
+		right, err := c.compileExpression(node.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		switch node.Operator {
+		case "!":
+			return c.emitTyped(ir.OpBang, ir.TypeBoolean, right), nil
+		case "-":
+			return c.emitTyped(ir.OpMinus, sameType(ir.TypeInteger, right), right), nil
+		default:
+			return nil, fmt.Errorf("unknown operator: %s", node.Operator)
+		}
 	case *ast.InfixExpression:
+		if node.Operator == "??" {
+			return c.compileCoalesce(node)
+		}
+
 		if node.Operator == "<" {
 			// 왼쪽 오른쪽 피연산자 순서가 바뀌어야 하기 때문에 컴파일 순서 자체를 바꾼다.
-			err := c.compileInfixExpressions(node.Right, node.Left)
+			operands, err := c.compileExpressions(node.Right, node.Left)
 			if err != nil {
-				return err
+				return nil, err
 			}
-
-			c.emit(code.OpGreaterThan)
-			return nil
+			return c.emitTyped(ir.OpGreaterThan, ir.TypeBoolean, operands...), nil
 		}
 
 		// 양쪽 left, right를 컴파일
-		err := c.compileInfixExpressions(node.Left, node.Right)
+		operands, err := c.compileExpressions(node.Left, node.Right)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		switch node.Operator {
 		case "+":
-			c.emit(code.OpAdd)
+			// 정수끼리 더하면 정수, 문자열끼리 더하면 문자열
+			typ := sameType(ir.TypeInteger, operands...)
+			if typ == ir.TypeAny {
+				typ = sameType(ir.TypeString, operands...)
+			}
+			return c.emitTyped(ir.OpAdd, typ, operands...), nil
 		case "-":
-			c.emit(code.OpSub)
+			return c.emitTyped(ir.OpSub, sameType(ir.TypeInteger, operands...), operands...), nil
 		case "*":
-			c.emit(code.OpMul)
+			return c.emitTyped(ir.OpMul, sameType(ir.TypeInteger, operands...), operands...), nil
 		case "/":
-			c.emit(code.OpDiv)
+			return c.emitTyped(ir.OpDiv, sameType(ir.TypeInteger, operands...), operands...), nil
 		case "==":
-			c.emit(code.OpEqual)
+			return c.emitTyped(ir.OpEqual, ir.TypeBoolean, operands...), nil
 		case "!=":
-			c.emit(code.OpNotEqual)
+			return c.emitTyped(ir.OpNotEqual, ir.TypeBoolean, operands...), nil
 		case ">":
-			c.emit(code.OpGreaterThan)
+			return c.emitTyped(ir.OpGreaterThan, ir.TypeBoolean, operands...), nil
 		default:
-			return fmt.Errorf("unknown operator: %s", node.Operator)
+			return nil, fmt.Errorf("unknown operator: %s", node.Operator)
 		}
 	case *ast.IntegerLiteral:
 		// 리터럴은 상수 표현식이므로, 값이 변하지 않아 *object.Integer를 생성
-		integer := &object.Integer{Value: node.Value}
-		c.emit(code.OpConstant, c.addConstant(integer))
+		return c.emitConst(&object.Integer{Value: node.Value}), nil
 	case *ast.Boolean:
-		if node.Value == true {
-			c.emit(code.OpTrue)
-		} else {
-			c.emit(code.OpFalse)
+		return c.emitConst(ir.Bool(node.Value)), nil
+	case *ast.Null:
+		return c.emitConst(object.NULL), nil
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.ArrayLiteral:
+		elements, err := c.compileExpressions(node.Elements...)
+		if err != nil {
+			return nil, err
+		}
+		return c.emitTyped(ir.OpArray, ir.TypeArray, elements...), nil
+	case *ast.HashLiteral:
+		// 해시가 소스에 적힌 순서를 유지하도록 node.Keys 순서대로 컴파일한다.
+		pairs := []ast.Expression{}
+		for _, k := range node.Keys {
+			pairs = append(pairs, k, node.Pairs[k])
 		}
+		operands, err := c.compileExpressions(pairs...)
+		if err != nil {
+			return nil, err
+		}
+		return c.emitTyped(ir.OpHash, ir.TypeHash, operands...), nil
+	case *ast.IndexExpression:
+		operands, err := c.compileExpressions(node.Left, node.Index)
+		if err != nil {
+			return nil, err
+		}
+
+		if node.Optional {
+			return c.emit(ir.OpSafeIndex, operands...), nil
+		}
+		return c.emit(ir.OpIndex, operands...), nil
+	case *ast.SliceExpression:
+		left, err := c.compileExpression(node.Left)
+		if err != nil {
+			return nil, err
+		}
+		operands := []*ir.Value{left}
+
+		// 생략된 범위는 null을 넣어 VM이 시퀀스의 처음이나 끝으로 해석하게 한다.
+		for _, bound := range []ast.Expression{node.Start, node.End} {
+			if bound == nil {
+				operands = append(operands, c.emitConst(object.NULL))
+				continue
+			}
+
+			value, err := c.compileExpression(bound)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, value)
+		}
+
+		if node.Optional {
+			return c.emit(ir.OpSafeSlice, operands...), nil
+		}
+		return c.emit(ir.OpSlice, operands...), nil
+	case *ast.StringLiteral:
+		return c.emitConst(&object.String{Value: node.Value}), nil
+	case *ast.InterpolatedString:
+		// 각 부분을 순서대로 스택에 쌓은 뒤, OpInterpolate가 한 번에 이어 붙인다.
+		parts, err := c.compileExpressions(node.Parts...)
+		if err != nil {
+			return nil, err
+		}
+		return c.emitTyped(ir.OpInterpolate, ir.TypeString, parts...), nil
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return nil, fmt.Errorf("undefined variable %s", node.Value)
+		}
+		return c.loadSymbol(symbol), nil
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+	case *ast.CallExpression:
+		fn, err := c.compileExpression(node.Function)
+		if err != nil {
+			return nil, err
+		}
+
+		args, err := c.compileExpressions(node.Arguments...)
+		if err != nil {
+			return nil, err
+		}
+
+		if node.Tail {
+			return c.emit(ir.OpTailCall, append([]*ir.Value{fn}, args...)...), nil
+		}
+		return c.emit(ir.OpCall, append([]*ir.Value{fn}, args...)...), nil
+	default:
+		return nil, fmt.Errorf("unknown expression: %T", node)
 	}
+}
 
-	return nil
+/*
+compileExpressions - 표현식들을 순서대로 컴파일한다. 값은 그 순서대로 스택에 쌓인다.
+*/
+func (c *Compiler) compileExpressions(nodes ...ast.Expression) ([]*ir.Value, error) {
+	values := []*ir.Value{}
+	for _, node := range nodes {
+		value, err := c.compileExpression(node)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+/*
+emit - 현재 블록에 값을 추가하고 그 값을 반환한다.
+현재 블록이 이미 끝났다면(return 뒤) 값은 어디서도 들어오지 않는 새 블록에 추가된다.
+*/
+func (c *Compiler) emit(op ir.Op, args ...*ir.Value) *ir.Value {
+	return c.emitTyped(op, ir.TypeAny, args...)
+}
+
+func (c *Compiler) emitTyped(op ir.Op, typ ir.Type, args ...*ir.Value) *ir.Value {
+	value := c.currentFunction().NewValue(op, typ, args...)
+
+	block := c.currentBlock()
+	block.Values = append(block.Values, value)
+
+	return value
+}
+
+func (c *Compiler) emitConst(obj object.Object) *ir.Value {
+	value := c.emitTyped(ir.OpConst, ir.TypeOf(obj))
+	value.Const = obj
+	return value
+}
+
+/*
+terminate - 현재 블록을 끝낸다.
+*/
+func (c *Compiler) terminate(term ir.Term) {
+	c.currentBlock().Term = term
+}
+
+func (c *Compiler) currentFunction() *ir.Function {
+	return c.scopes[c.scopeIndex].function
+}
+
+/*
+currentBlock - 값을 추가할 블록. 이미 끝난 블록이라면 새 블록을 만든다.
+*/
+func (c *Compiler) currentBlock() *ir.Block {
+	scope := &c.scopes[c.scopeIndex]
+	if scope.block.Terminated() {
+		scope.block = scope.function.NewBlock()
+	}
+	return scope.block
+}
+
+/*
+startBlock - 새 블록을 만들어 이후의 값을 그 블록에 추가한다.
+블록은 만든 순서대로 배치되므로 갈래는 실행 순서대로 만든다.
+*/
+func (c *Compiler) startBlock() *ir.Block {
+	block := c.currentFunction().NewBlock()
+	c.scopes[c.scopeIndex].block = block
+	return block
+}
+
+func (c *Compiler) enterScope(fn *ir.Function) {
+	c.scopes = append(c.scopes, CompilationScope{function: fn, block: fn.NewBlock()})
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() *ir.Function {
+	fn := c.currentFunction()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return fn
+}
+
+func (c *Compiler) loadSymbol(s Symbol) *ir.Value {
+	value := c.emit(ir.OpLoad)
+	value.Var = irVar(s)
+	if s.Scope == FunctionScope {
+		value.Type = ir.TypeFunction
+	}
+	return value
+}
+
+func irVar(s Symbol) ir.Var {
+	scopes := map[SymbolScope]ir.Scope{
+		GlobalScope:   ir.Global,
+		LocalScope:    ir.Local,
+		BuiltinScope:  ir.Builtin,
+		FreeScope:     ir.Free,
+		FunctionScope: ir.Self,
+	}
+	return ir.Var{Scope: scopes[s.Scope], Index: s.Index, Name: s.Name}
+}
+
+/*
+sameType - 피연산자가 모두 typ이면 typ, 아니면 알 수 없다.
+*/
+func sameType(typ ir.Type, values ...*ir.Value) ir.Type {
+	for _, v := range values {
+		if v.Type != typ {
+			return ir.TypeAny
+		}
+	}
+	return typ
+}
+
+/*
+keepBlockValue - 블록의 마지막 표현식 값이 스택에 남도록 끝의 pop을 지우고 그 값을 돌려준다.
+블록이 비어있거나 let 같은 문장으로 끝나서 남길 값이 없다면 null을 대신 남긴다.
+블록이 return으로 끝났다면 합쳐질 값이 없으므로 nil을 돌려준다.
+*/
+func (c *Compiler) keepBlockValue() *ir.Value {
+	block := c.scopes[c.scopeIndex].block
+	if block.Terminated() {
+		return nil
+	}
+	if len(block.Values) > 0 {
+		last := block.Values[len(block.Values)-1]
+		if last.Op == ir.OpPop {
+			block.Values = block.Values[:len(block.Values)-1]
+			return last.Args[0]
+		}
+	}
+
+	return c.emitConst(object.NULL)
 }
 
 /*
-addConstant - OpConstant 명령어가 사용할 피연산자.
-가상 머신에게 이 상수를 상수 풀에서 가져와 콜 스택에 집어 넣게 만드는 역할
+compileIfExpression
+조건식이 참이 아니면 consequence를 건너뛰고, consequence를 실행했다면 alternative를 건너뛴다.
+if도 표현식이기 때문에 두 갈래의 마지막 값을 phi로 합친다. (keepBlockValue)
+alternative가 없으면 그 자리에서 null을 만든다. return으로 끝난 갈래는 END로 가지 않는다.
+
+	<condition>
+	branch CONSEQUENCE, ALTERNATIVE
+	CONSEQUENCE: <consequence>
+	jump END
+	ALTERNATIVE: <alternative> 또는 null
+	jump END
+	END: phi
 */
-func (c *Compiler) addConstant(obj object.Object) int {
-	c.constants = append(c.constants, obj)
-	return len(c.constants) - 1
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) (*ir.Value, error) {
+	condition, err := c.compileExpression(node.Condition)
+	if err != nil {
+		return nil, err
+	}
+	conditionBlock := c.currentBlock()
+
+	consequenceBlock := c.startBlock()
+	err = c.compileStatement(node.Consequence)
+	if err != nil {
+		return nil, err
+	}
+	consequence := c.keepBlockValue()
+	consequenceEnd := c.scopes[c.scopeIndex].block
+
+	alternativeBlock := c.startBlock()
+	conditionBlock.Term = ir.Term{Kind: ir.Branch, Value: condition, Targets: []*ir.Block{consequenceBlock, alternativeBlock}}
+
+	var alternative *ir.Value
+	if node.Alternative == nil {
+		alternative = c.emitConst(object.NULL)
+	} else {
+		err := c.compileStatement(node.Alternative)
+		if err != nil {
+			return nil, err
+		}
+		alternative = c.keepBlockValue()
+	}
+	alternativeEnd := c.scopes[c.scopeIndex].block
+
+	return c.join(consequence, consequenceEnd, alternative, alternativeEnd), nil
 }
 
 /*
-emit - 명령어를 만들고(instruction) 명령어 배열에 해당 명령어를 추가한 다음, 해당 명령어의 위치를 반환
+compileCoalesce - left ?? right
+left가 null이 아니면 right를 평가하지 않고 건너뛴다.
+
+	<left>
+	branchnotnull END, RIGHT
+	RIGHT: <right>
+	jump END
+	END: phi
 */
-func (c *Compiler) emit(op code.Opcode, operands ...int) int {
-	instructions := code.Make(op, operands...)
-	position := c.addInstruction(instructions)
-	// 지금 만들어낸 명령어의 시작 위치를 반환
-	return position
+func (c *Compiler) compileCoalesce(node *ast.InfixExpression) (*ir.Value, error) {
+	left, err := c.compileExpression(node.Left)
+	if err != nil {
+		return nil, err
+	}
+	leftBlock := c.currentBlock()
+
+	rightBlock := c.startBlock()
+	// END 블록은 right 뒤에 만들어지므로 그때 채운다.
+	leftBlock.Term = ir.Term{Kind: ir.BranchNotNull, Value: left, Targets: []*ir.Block{nil, rightBlock}}
+
+	right, err := c.compileExpression(node.Right)
+	if err != nil {
+		return nil, err
+	}
+	rightEnd := c.currentBlock()
+
+	phi := c.join(left, leftBlock, right, rightEnd)
+	leftBlock.Term.Targets[0] = c.currentBlock()
+
+	return phi, nil
+}
+
+/*
+join - 두 갈래가 합쳐지는 새 블록을 만든다. 값이 있는 갈래는 그 블록으로 점프하고, 값은 phi로 고른다.
+값이 nil인 갈래는 이미 return으로 끝났으므로 phi의 피연산자에서 뺀다.
+값이 있는 갈래가 하나뿐이어도 phi를 남긴다. 그 값은 다른 블록에 있으므로 END의 값이 직접 피연산자로 쓰면 안 된다.
+*/
+func (c *Compiler) join(first *ir.Value, firstEnd *ir.Block, second *ir.Value, secondEnd *ir.Block) *ir.Value {
+	end := c.startBlock()
+
+	values, preds := []*ir.Value{}, []*ir.Block{}
+	for i, block := range []*ir.Block{firstEnd, secondEnd} {
+		value := []*ir.Value{first, second}[i]
+		if value == nil {
+			continue
+		}
+		// ??의 왼쪽 갈래는 이미 END로 가는 branchnotnull로 끝났다.
+		if !block.Terminated() {
+			block.Term = ir.Term{Kind: ir.Jump, Targets: []*ir.Block{end}}
+		}
+		values = append(values, value)
+		preds = append(preds, block)
+	}
+
+	if len(values) == 0 {
+		// 두 갈래 모두 반환했으므로 END는 실행되지 않는다. 표현식의 자리만 채운다.
+		return c.emitConst(object.NULL)
+	}
+
+	typ := values[0].Type
+	for _, value := range values[1:] {
+		if value.Type != typ {
+			typ = ir.TypeAny
+		}
+	}
+	phi := c.emitTyped(ir.OpPhi, typ, values...)
+	phi.Preds = preds
+
+	return phi
 }
 
-func (c *Compiler) addInstruction(instructions []byte) int {
-	newInstructionPosition := len(c.instructions)
-	c.instructions = append(c.instructions, instructions...)
-	return newInstructionPosition
+/*
+compileFunctionLiteral - 본문을 새 스코프의 ir.Function으로 만든다.
+본문이 참조한 바깥 함수의 지역 바인딩(자유 변수)을 읽은 값과 함께 closure로 묶는다.
+*/
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) (*ir.Value, error) {
+	c.enterScope(ir.NewFunction(node.Name, len(node.Parameters)))
+
+	if node.Name != "" {
+		c.symbolTable.DefineFunctionName(node.Name)
+	}
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+
+	err := c.compileStatement(node.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// 본문의 마지막 표현식 값을 암묵적으로 반환한다. 반환할 값이 없으면 null을 반환한다.
+	block := c.scopes[c.scopeIndex].block
+	if !block.Terminated() {
+		if n := len(block.Values); n > 0 && block.Values[n-1].Op == ir.OpPop {
+			value := block.Values[n-1].Args[0]
+			block.Values = block.Values[:n-1]
+			block.Term = ir.Term{Kind: ir.Return, Value: value}
+		} else {
+			block.Term = ir.Term{Kind: ir.ReturnNull}
+		}
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	fn := c.leaveScope()
+	fn.NumLocals = numLocals
+
+	free := []*ir.Value{}
+	for _, s := range freeSymbols {
+		free = append(free, c.loadSymbol(s))
+	}
+
+	closure := c.emitTyped(ir.OpClosure, ir.TypeFunction, free...)
+	closure.Func = fn
+
+	return closure, nil
 }
 
 /*
@@ -124,18 +660,13 @@ type Bytecode struct {
 }
 
 func (c *Compiler) Bytecode() *Bytecode {
-	return &Bytecode{
-		Instructions: c.instructions,
-		Constants:    c.constants,
+	instructions := c.instructions
+	if c.optimize {
+		instructions = optimizeInstructions(instructions, true)
 	}
-}
 
-func (c *Compiler) compileInfixExpressions(nodes ...ast.Node) error {
-	for _, node := range nodes {
-		err := c.Compile(node)
-		if err != nil {
-			return err
-		}
+	return &Bytecode{
+		Instructions: instructions,
+		Constants:    c.constants,
 	}
-	return nil
 }
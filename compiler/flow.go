@@ -0,0 +1,160 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+)
+
+/*
+Warning - 컴파일은 되지만 의심스러운 코드에 대한 경고. 위치는 문제가 된 토큰의 줄과 열이다.
+파서 에러와 달리 컴파일을 멈추지 않으며, Compiler.Warnings로 따로 꺼내 본다.
+*/
+type Warning struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d, column %d: %s", w.Line, w.Column, w.Message)
+}
+
+/*
+Warnings - 지금까지 컴파일하며 모인 경고
+*/
+func (c *Compiler) Warnings() []Warning {
+	return c.warnings
+}
+
+func (c *Compiler) warn(tok token.Token, format string, args ...interface{}) {
+	c.warnings = append(c.warnings, Warning{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+/*
+Analyze - node를 컴파일하지 않고 Compile과 같은 경고를 같은 순서로 모은다.
+컴파일하지 않는 평가기로 실행하는 프로그램도 같은 진단을 받을 수 있게 하기 위함
+*/
+func Analyze(node ast.Node) []Warning {
+	c := &Compiler{}
+	c.analyze(node)
+	return c.warnings
+}
+
+/*
+analyze - Compile이 노드를 컴파일하는 순서대로 하위 노드를 방문하며 블록마다 reachableStatements를 적용한다.
+*/
+func (c *Compiler) analyze(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, stmt := range c.reachableStatements(node.Statements) {
+			c.analyze(stmt)
+		}
+	case *ast.BlockStatement:
+		for _, stmt := range c.reachableStatements(node.Statements) {
+			c.analyze(stmt)
+		}
+	case *ast.ExpressionStatement:
+		c.analyze(node.Expression)
+	case *ast.LetStatement:
+		c.analyze(node.Value)
+	case *ast.ReturnStatement:
+		c.analyze(node.ReturnValue)
+	case *ast.PrefixExpression:
+		c.analyze(node.Right)
+	case *ast.InfixExpression:
+		if node.Operator == "<" {
+			c.analyzeAll(node.Right, node.Left)
+		} else {
+			c.analyzeAll(node.Left, node.Right)
+		}
+	case *ast.IfExpression:
+		c.analyze(node.Condition)
+		c.analyze(node.Consequence)
+		if node.Alternative != nil {
+			c.analyze(node.Alternative)
+		}
+	case *ast.ArrayLiteral:
+		c.analyzeAll(node.Elements...)
+	case *ast.HashLiteral:
+		for _, k := range node.Keys {
+			c.analyzeAll(k, node.Pairs[k])
+		}
+	case *ast.IndexExpression:
+		c.analyzeAll(node.Left, node.Index)
+	case *ast.SliceExpression:
+		c.analyzeAll(node.Left, node.Start, node.End)
+	case *ast.InterpolatedString:
+		c.analyzeAll(node.Parts...)
+	case *ast.FunctionLiteral:
+		c.analyze(node.Body)
+	case *ast.CallExpression:
+		c.analyze(node.Function)
+		c.analyzeAll(node.Arguments...)
+	}
+}
+
+func (c *Compiler) analyzeAll(nodes ...ast.Expression) {
+	for _, node := range nodes {
+		if node != nil {
+			c.analyze(node)
+		}
+	}
+}
+
+/*
+reachableStatements - 블록이나 프로그램에서 실행될 수 있는 앞부분의 문장만 돌려준다.
+반드시 반환하는 문장(terminates) 뒤의 문장은 실행될 일이 없으므로 컴파일하지 않고, 그 첫 문장의 위치에 경고를 남긴다.
+*/
+func (c *Compiler) reachableStatements(statements []ast.Statement) []ast.Statement {
+	for i, stmt := range statements {
+		if terminates(stmt) && i < len(statements)-1 {
+			c.warn(statementToken(statements[i+1]), "unreachable code")
+			return statements[:i+1]
+		}
+	}
+	return statements
+}
+
+/*
+terminates - 문장을 실행하면 반드시 반환하는지.
+return 문이거나, 두 갈래가 모두 반드시 반환하는 if 표현식이다.
+*/
+func terminates(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.ExpressionStatement:
+		ie, ok := stmt.Expression.(*ast.IfExpression)
+		if !ok || ie.Alternative == nil {
+			return false
+		}
+		return blockTerminates(ie.Consequence) && blockTerminates(ie.Alternative)
+	default:
+		return false
+	}
+}
+
+func blockTerminates(block *ast.BlockStatement) bool {
+	for _, stmt := range block.Statements {
+		if terminates(stmt) {
+			return true
+		}
+	}
+	return false
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	case *ast.BlockStatement:
+		return stmt.Token
+	default:
+		return token.Token{}
+	}
+}
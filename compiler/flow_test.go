@@ -0,0 +1,144 @@
+package compiler
+
+import (
+	"monkey/code"
+	"testing"
+)
+
+func TestUnreachableCode(t *testing.T) {
+	tests := []struct {
+		input                string
+		expectedConstants    []interface{}
+		expectedInstructions []code.Instructions
+		expectedWarnings     []string
+	}{
+		{
+			input: "fn() {\n  return 1;\n  let x = 2;\n  x\n}",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+			expectedWarnings: []string{"line 3, column 3: unreachable code"},
+		},
+		// 두 갈래가 모두 반환하는 if 뒤의 코드
+		{
+			input: `fn(a) { if (a) { return 1; } else { if (a) { return 2 } else { return 3; 4 } }; 5 }`,
+			expectedConstants: []interface{}{
+				1,
+				2,
+				3,
+				// 반환한 갈래는 if의 끝으로 점프하지 않고, 두 갈래가 모두 반환한 if의 끝은 내보내지 않는다.
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpGetLocal, 0),
+					// 0002
+					code.Make(code.OpJumpNotTruthy, 9),
+					// 0005
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpReturnValue),
+					// 0009
+					code.Make(code.OpGetLocal, 0),
+					// 0011
+					code.Make(code.OpJumpNotTruthy, 18),
+					// 0014
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpReturnValue),
+					// 0018
+					code.Make(code.OpConstant, 2),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpPop),
+			},
+			expectedWarnings: []string{
+				"line 1, column 81: unreachable code",
+				"line 1, column 74: unreachable code",
+			},
+		},
+		// 한쪽 갈래만 반환하면 뒤의 코드는 실행될 수 있다.
+		{
+			input:             `return 1; if (true) { return 2 }; 3`,
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpReturnValue),
+			},
+			expectedWarnings: []string{"line 1, column 11: unreachable code"},
+		},
+		{
+			input: `fn(a) { if (a) { return 1 }; 2 }`,
+		},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		warnings := compiler.Warnings()
+		if len(warnings) != len(tt.expectedWarnings) {
+			t.Fatalf("%s: wrong number of warnings. want=%q, got=%v", tt.input, tt.expectedWarnings, warnings)
+		}
+		for i, warning := range warnings {
+			if warning.String() != tt.expectedWarnings[i] {
+				t.Errorf("%s: wrong warning. want=%q, got=%q", tt.input, tt.expectedWarnings[i], warning)
+			}
+		}
+
+		if tt.expectedInstructions == nil {
+			continue
+		}
+		bytecode := compiler.Bytecode()
+		err = testInstructions(tt.expectedInstructions, bytecode.Instructions)
+		if err != nil {
+			t.Fatalf("%s: testInstructions failed: %s", tt.input, err)
+		}
+		err = testConstants(t, tt.expectedConstants, bytecode.Constants)
+		if err != nil {
+			t.Fatalf("%s: testConstants failed: %s", tt.input, err)
+		}
+	}
+}
+
+/*
+Analyze는 컴파일하지 않고도 Compile과 같은 경고를 같은 순서로 돌려준다.
+*/
+func TestAnalyze(t *testing.T) {
+	inputs := []string{
+		"fn() {\n  return 1;\n  let x = 2;\n  x\n}",
+		`fn(a) { if (a) { return 1; } else { if (a) { return 2 } else { return 3; 4 } }; 5 }`,
+		`return 1; if (true) { return 2 }; 3`,
+		`[fn() { return 1; 2 }(), {"a": fn() { return 3; 4 }}][0:fn() { return 1; 5 }()]`,
+		`fn(a) { if (a) { return 1 }; 2 }`,
+	}
+
+	for _, input := range inputs {
+		program := parse(input)
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		want := compiler.Warnings()
+		got := Analyze(program)
+		if len(got) != len(want) {
+			t.Fatalf("%s: wrong number of warnings. want=%v, got=%v", input, want, got)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%s: wrong warning. want=%q, got=%q", input, want[i], got[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package compiler
+
+import (
+	"monkey/code"
+	"monkey/ir"
+	"monkey/object"
+)
+
+/*
+lower - ir.Function을 명령어로 바꾼다.
+블록은 배치 순서대로 내보내고, 블록이 시작하는 위치를 모두 안 뒤에 점프의 피연산자를 채운다.
+첫 블록에서 갈 수 없는 블록(두 갈래가 모두 반환한 if의 뒤)은 실행될 일이 없으므로 내보내지 않는다.
+점프는 명령어 전체에서의 절대 위치이므로 이미 컴파일한 명령어 뒤에 붙일 때는 그 길이(base)부터 센다.
+*/
+func (c *Compiler) lower(fn *ir.Function, base int) code.Instructions {
+	l := lowering{compiler: c, blocks: map[*ir.Block]int{}}
+
+	blocks := reachableBlocks(fn)
+	for i, block := range blocks {
+		l.blocks[block] = base + len(l.instructions)
+
+		for _, v := range block.Values {
+			l.value(v)
+		}
+
+		var next *ir.Block
+		if i+1 < len(blocks) {
+			next = blocks[i+1]
+		}
+		l.term(block.Term, next)
+	}
+
+	for _, f := range l.fixups {
+		copy(l.instructions[f.position:], code.Make(f.op, l.blocks[f.target]))
+	}
+
+	return l.instructions
+}
+
+/*
+reachableBlocks - 첫 블록에서 종결자를 따라 갈 수 있는 블록. 배치 순서는 유지한다.
+*/
+func reachableBlocks(fn *ir.Function) []*ir.Block {
+	reachable := map[*ir.Block]bool{}
+	work := []*ir.Block{fn.Blocks[0]}
+	for len(work) > 0 {
+		block := work[len(work)-1]
+		work = work[:len(work)-1]
+		if reachable[block] {
+			continue
+		}
+		reachable[block] = true
+		work = append(work, block.Term.Targets...)
+	}
+
+	blocks := []*ir.Block{}
+	for _, block := range fn.Blocks {
+		if reachable[block] {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+type lowering struct {
+	compiler     *Compiler
+	instructions code.Instructions
+	// 블록이 시작하는 위치
+	blocks map[*ir.Block]int
+	// 블록 위치를 알고 나서 채울 점프
+	fixups []jumpFixup
+}
+
+type jumpFixup struct {
+	position int
+	op       code.Opcode
+	target   *ir.Block
+}
+
+// 피연산자 없이 명령어 하나가 되는 연산
+var simpleOpcodes = map[ir.Op]code.Opcode{
+	ir.OpAdd:         code.OpAdd,
+	ir.OpSub:         code.OpSub,
+	ir.OpMul:         code.OpMul,
+	ir.OpDiv:         code.OpDiv,
+	ir.OpEqual:       code.OpEqual,
+	ir.OpNotEqual:    code.OpNotEqual,
+	ir.OpGreaterThan: code.OpGreaterThan,
+	ir.OpMinus:       code.OpMinus,
+	ir.OpBang:        code.OpBang,
+	ir.OpIndex:       code.OpIndex,
+	ir.OpSafeIndex:   code.OpSafeIndex,
+	ir.OpSlice:       code.OpSlice,
+	ir.OpSafeSlice:   code.OpSafeSlice,
+	ir.OpPop:         code.OpPop,
+}
+
+func (l *lowering) emit(op code.Opcode, operands ...int) {
+	l.instructions = append(l.instructions, code.Make(op, operands...)...)
+}
+
+func (l *lowering) jump(op code.Opcode, target *ir.Block) {
+	l.fixups = append(l.fixups, jumpFixup{position: len(l.instructions), op: op, target: target})
+	// 위치는 나중에 채운다.
+	l.emit(op, 9999)
+}
+
+func (l *lowering) value(v *ir.Value) {
+	if op, ok := simpleOpcodes[v.Op]; ok {
+		l.emit(op)
+		return
+	}
+
+	switch v.Op {
+	case ir.OpConst:
+		switch v.Const {
+		case object.TRUE:
+			l.emit(code.OpTrue)
+		case object.FALSE:
+			l.emit(code.OpFalse)
+		case object.NULL:
+			l.emit(code.OpNull)
+		default:
+			l.emit(code.OpConstant, l.compiler.addConstant(v.Const))
+		}
+	case ir.OpLoad:
+		switch v.Var.Scope {
+		case ir.Global:
+			l.emit(code.OpGetGlobal, v.Var.Index)
+		case ir.Local:
+			l.emit(code.OpGetLocal, v.Var.Index)
+		case ir.Builtin:
+			l.emit(code.OpGetBuiltin, v.Var.Index)
+		case ir.Free:
+			l.emit(code.OpGetFree, v.Var.Index)
+		case ir.Self:
+			l.emit(code.OpCurrentClosure)
+		}
+	case ir.OpStore:
+		if v.Var.Scope == ir.Global {
+			l.emit(code.OpSetGlobal, v.Var.Index)
+		} else {
+			l.emit(code.OpSetLocal, v.Var.Index)
+		}
+	case ir.OpInterpolate:
+		l.emit(code.OpInterpolate, len(v.Args))
+	case ir.OpArray:
+		l.emit(code.OpArray, len(v.Args))
+	case ir.OpHash:
+		l.emit(code.OpHash, len(v.Args))
+	case ir.OpCall:
+		l.emit(code.OpCall, len(v.Args)-1)
+	case ir.OpTailCall:
+		l.emit(code.OpTailCall, len(v.Args)-1)
+	case ir.OpClosure:
+		l.emit(code.OpClosure, l.compiler.addConstant(l.compiler.compiledFunction(v.Func)), len(v.Args))
+	case ir.OpPhi:
+		// 피연산자가 이미 스택 최상단에 있다.
+	}
+}
+
+/*
+term - 블록의 종결자. 바로 다음에 배치된 블록으로 가는 점프는 내보내지 않는다.
+*/
+func (l *lowering) term(t ir.Term, next *ir.Block) {
+	switch t.Kind {
+	case ir.Jump:
+		if t.Targets[0] != next {
+			l.jump(code.OpJump, t.Targets[0])
+		}
+	case ir.Branch:
+		l.jump(code.OpJumpNotTruthy, t.Targets[1])
+		if t.Targets[0] != next {
+			l.jump(code.OpJump, t.Targets[0])
+		}
+	case ir.BranchNotNull:
+		l.jump(code.OpJumpNotNull, t.Targets[0])
+		if t.Targets[1] != next {
+			l.jump(code.OpJump, t.Targets[1])
+		}
+	case ir.Return:
+		l.emit(code.OpReturnValue)
+	case ir.ReturnNull:
+		l.emit(code.OpReturn)
+	}
+}
+
+/*
+compiledFunction - 클로저의 함수를 명령어로 바꿔 상수 풀에 넣을 CompiledFunction으로 만든다.
+함수 본문의 점프는 함수 명령어의 처음부터 센다.
+*/
+func (c *Compiler) compiledFunction(fn *ir.Function) *object.CompiledFunction {
+	instructions := c.lower(fn, 0)
+	if c.optimize {
+		instructions = optimizeInstructions(instructions, false)
+	}
+
+	return &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     fn.NumLocals,
+		NumParameters: fn.NumParameters,
+	}
+}
+
+/*
+addConstant - OpConstant 명령어가 사용할 피연산자.
+가상 머신에게 이 상수를 상수 풀에서 가져와 콜 스택에 집어 넣게 만드는 역할
+최적화할 때는 같은 정수나 문자열 상수가 이미 있으면 그 위치를 재사용한다.
+*/
+func (c *Compiler) addConstant(obj object.Object) int {
+	if c.optimize {
+		if index, ok := c.constantIndex(obj); ok {
+			return index
+		}
+	}
+
+	c.constants = append(c.constants, obj)
+	index := len(c.constants) - 1
+
+	if key, ok := constantKey(obj); ok && c.constantIndexes != nil {
+		c.constantIndexes[key] = index
+	}
+
+	return index
+}
@@ -0,0 +1,92 @@
+package compiler
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIRDump(t *testing.T) {
+	tests := []struct {
+		input    string
+		options  []Option
+		expected string
+	}{
+		{
+			input: `let x = 1; if (x > 0) { "a" } else { "b" }`,
+			expected: `function #0 main
+b0:
+  %1:int = const 1
+  store global 0 (x), %1
+  %2:any = load global 0 (x)
+  %3:int = const 0
+  %4:bool = gt %2, %3
+  branch %4, b1, b2
+b1:
+  %5:string = const "a"
+  jump b3
+b2:
+  %6:string = const "b"
+  jump b3
+b3:
+  %7:string = phi [b1: %5], [b2: %6]
+  pop %7
+  exit
+`,
+		},
+		// 폴딩한 값은 접힌 연산의 번호를 그대로 쓴다.
+		{
+			input:   `null ?? 2 * 3`,
+			options: []Option{WithOptimizations()},
+			expected: `function #0 main
+b0:
+  %1:null = const null
+  branchnotnull %1, b2, b1
+b1:
+  %4:int = const 6
+  jump b2
+b2:
+  %5:any = phi [b0: %1], [b1: %4]
+  pop %5
+  exit
+`,
+		},
+		// 반환한 갈래는 phi에 들어가지 않는다. 남은 갈래가 하나여도 phi는 END 블록에 남는다.
+		{
+			input: `fn(n) { if (n) { return n; }; n }`,
+			expected: `function #0 main
+b0:
+  %1:fn = closure #1
+  pop %1
+  exit
+function #1 <anonymous> (params=1, locals=1)
+b0:
+  %1:any = load local 0 (n)
+  branch %1, b1, b2
+b1:
+  %2:any = load local 0 (n)
+  return %2
+b2:
+  %3:null = const null
+  jump b3
+b3:
+  %4:null = phi [b2: %3]
+  pop %4
+  %5:any = load local 0 (n)
+  return %5
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		compiler := New(append(tt.options, WithIRDump(&out))...)
+		err := compiler.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		if out.String() != tt.expected {
+			t.Errorf("%s: wrong IR.\nwant=\n%s\ngot=\n%s", tt.input, tt.expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package compiler
+
+import (
+	"monkey/code"
+	"monkey/object"
+	"sort"
+)
+
+/*
+WithOptimizations - 최적화를 켠다. 컴파일 결과의 의미는 그대로이고 명령어와 상수만 줄어든다.
+
+  - 상수 폴딩: 피연산자가 모두 상수인 IR 연산은 명령어로 바꾸기 전에 계산해 상수로 만든다. (ir.FoldConstants)
+  - 상수 중복 제거: 같은 정수나 문자열 상수는 상수 풀에 한 번만 넣는다.
+  - 핍홀 최적화: 스코프 하나를 다 컴파일한 뒤 명령어를 훑으며 쓸데없는 패턴을 지운다. (optimizeInstructions)
+*/
+func WithOptimizations() Option {
+	return func(c *Compiler) {
+		c.optimize = true
+	}
+}
+
+/*
+constantIndex - 이미 상수 풀에 있는 같은 정수나 문자열 상수의 위치.
+NewWithState로 이어받은 상수도 찾을 수 있도록 처음 찾을 때 상수 풀 전체로 색인을 만든다.
+*/
+func (c *Compiler) constantIndex(obj object.Object) (int, bool) {
+	key, ok := constantKey(obj)
+	if !ok {
+		return 0, false
+	}
+
+	if c.constantIndexes == nil {
+		c.constantIndexes = map[object.HashKey]int{}
+		for i, constant := range c.constants {
+			if k, ok := constantKey(constant); ok {
+				if _, exists := c.constantIndexes[k]; !exists {
+					c.constantIndexes[k] = i
+				}
+			}
+		}
+	}
+
+	index, ok := c.constantIndexes[key]
+	return index, ok
+}
+
+func constantKey(obj object.Object) (object.HashKey, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.HashKey(), true
+	case *object.String:
+		return obj.HashKey(), true
+	default:
+		return object.HashKey{}, false
+	}
+}
+
+/*
+instruction - 핍홀 최적화 중의 명령어 하나.
+점프의 피연산자는 원래 명령어 배열의 위치 그대로 두고, 다시 인코딩할 때 새 위치로 옮긴다.
+*/
+type instruction struct {
+	op       code.Opcode
+	operands []int
+	position int
+	removed  bool
+}
+
+/*
+optimizeInstructions - 스코프 하나의 명령어에서 다음 패턴을 더 이상 바뀌지 않을 때까지 지운다.
+
+  - 값을 넣자마자 꺼내는 쌍: OpConstant, OpGetLocal 등 부수 효과 없이 값을 넣는 명령어와 바로 뒤의 OpPop
+  - 조건이 상수인 조건부 점프: OpTrue, OpJumpNotTruthy는 둘 다 지우고, OpFalse, OpJumpNotTruthy는 OpJump로 바꾼다.
+  - 점프로 가는 점프: 도착지가 OpJump라면 그 OpJump의 도착지로 바로 간다.
+  - 바로 다음 명령어로 가는 OpJump
+
+점프의 도착지인 명령어는 앞 명령어와 한 쌍으로 지우지 않는다. 다른 곳에서 그 명령어로 바로 들어올 수 있기 때문이다.
+main 스코프는 VM의 LastPoppedStackElement가 프로그램의 결과가 되므로 마지막 OpPop을 남겨둔다. (keepLastPop)
+*/
+func optimizeInstructions(ins code.Instructions, keepLastPop bool) code.Instructions {
+	list := decodeInstructions(ins)
+
+	for changed := true; changed; {
+		changed = false
+		targets := jumpTargets(list)
+
+		for i := range list {
+			if list[i].removed {
+				continue
+			}
+			current := &list[i]
+			j := nextInstruction(list, i)
+
+			if isJump(current.op) {
+				t := resolveTarget(list, current.operands[0])
+				if t != -1 && t != i && list[t].op == code.OpJump && list[t].operands[0] != current.operands[0] {
+					current.operands[0] = list[t].operands[0]
+					changed = true
+					continue
+				}
+				if current.op == code.OpJump && t == j {
+					current.removed = true
+					changed = true
+					continue
+				}
+			}
+
+			if j == -1 || targets[j] {
+				continue
+			}
+			next := &list[j]
+
+			switch {
+			case isPush(current.op) && next.op == code.OpPop:
+				if keepLastPop && nextInstruction(list, j) == -1 {
+					continue
+				}
+				current.removed, next.removed = true, true
+				changed = true
+			case (current.op == code.OpTrue || current.op == code.OpConstant) && next.op == code.OpJumpNotTruthy:
+				current.removed, next.removed = true, true
+				changed = true
+			case (current.op == code.OpFalse || current.op == code.OpNull) && next.op == code.OpJumpNotTruthy:
+				current.removed = true
+				next.op = code.OpJump
+				changed = true
+			}
+		}
+	}
+
+	return encodeInstructions(list)
+}
+
+func decodeInstructions(ins code.Instructions) []instruction {
+	list := []instruction{}
+
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			panic(err)
+		}
+
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		list = append(list, instruction{op: code.Opcode(ins[i]), operands: operands, position: i})
+
+		i += 1 + read
+	}
+
+	return list
+}
+
+/*
+encodeInstructions - 남은 명령어를 다시 이어 붙인다. 점프는 원래 도착지에서 처음으로 남아있는 명령어의 새 위치로 옮긴다.
+*/
+func encodeInstructions(list []instruction) code.Instructions {
+	newPositions := make([]int, len(list)+1)
+	position := 0
+	for i, ins := range list {
+		newPositions[i] = position
+		if !ins.removed {
+			position += len(code.Make(ins.op, ins.operands...))
+		}
+	}
+	newPositions[len(list)] = position
+
+	out := code.Instructions{}
+	for _, ins := range list {
+		if ins.removed {
+			continue
+		}
+
+		operands := ins.operands
+		if isJump(ins.op) {
+			t := resolveTarget(list, operands[0])
+			if t == -1 {
+				t = len(list)
+			}
+			operands = []int{newPositions[t]}
+		}
+		out = append(out, code.Make(ins.op, operands...)...)
+	}
+
+	return out
+}
+
+func isJump(op code.Opcode) bool {
+	return op == code.OpJump || op == code.OpJumpNotTruthy || op == code.OpJumpNotNull
+}
+
+func isPush(op code.Opcode) bool {
+	switch op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull,
+		code.OpGetGlobal, code.OpGetLocal, code.OpGetFree, code.OpGetBuiltin, code.OpCurrentClosure:
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+jumpTargets - 남아있는 점프가 실제로 도착하는 명령어들
+*/
+func jumpTargets(list []instruction) map[int]bool {
+	targets := map[int]bool{}
+	for _, ins := range list {
+		if !ins.removed && isJump(ins.op) {
+			if t := resolveTarget(list, ins.operands[0]); t != -1 {
+				targets[t] = true
+			}
+		}
+	}
+	return targets
+}
+
+/*
+resolveTarget - 원래 위치 position 이후로 처음 남아있는 명령어. 없으면(명령어 배열의 끝) -1
+*/
+func resolveTarget(list []instruction, position int) int {
+	i := sort.Search(len(list), func(i int) bool { return list[i].position >= position })
+	if i < len(list) && !list[i].removed {
+		return i
+	}
+	return nextInstruction(list, i)
+}
+
+func nextInstruction(list []instruction, i int) int {
+	for j := i + 1; j < len(list); j++ {
+		if !list[j].removed {
+			return j
+		}
+	}
+	return -1
+}
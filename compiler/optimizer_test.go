@@ -0,0 +1,356 @@
+package compiler
+
+import (
+	"monkey/code"
+	"testing"
+)
+
+type optimizerTestCase struct {
+	input             string
+	before            []code.Instructions
+	after             []code.Instructions
+	expectedConstants []interface{}
+}
+
+func TestConstantFolding(t *testing.T) {
+	tests := []optimizerTestCase{
+		{
+			input: "1 + 2 * 3",
+			before: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpMul),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+			after: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+			expectedConstants: []interface{}{7},
+		},
+		{
+			input: `-(10 / 3) < 0 == !null`,
+			before: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpDiv),
+				code.Make(code.OpMinus),
+				code.Make(code.OpGreaterThan),
+				code.Make(code.OpNull),
+				code.Make(code.OpBang),
+				code.Make(code.OpEqual),
+				code.Make(code.OpPop),
+			},
+			after: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpPop),
+			},
+			expectedConstants: []interface{}{},
+		},
+		{
+			input: `let x = "!"; "mon" + "key" + x`,
+			before: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+			after: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+			expectedConstants: []interface{}{"!", "monkey"},
+		},
+		// 실행할 때 에러가 나는 표현식은 그대로 둔다.
+		{
+			input: `1 / 0; 1 + "a"`,
+			before: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpDiv),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+			after: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpDiv),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+			expectedConstants: []interface{}{1, 0, "a"},
+		},
+	}
+
+	runOptimizerTests(t, tests)
+}
+
+func TestConstantDeduplication(t *testing.T) {
+	tests := []optimizerTestCase{
+		{
+			input: `[1, "a", 1, "a", fn() { 1 }]`,
+			before: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpClosure, 5, 0),
+				code.Make(code.OpArray, 5),
+				code.Make(code.OpPop),
+			},
+			after: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpArray, 5),
+				code.Make(code.OpPop),
+			},
+			expectedConstants: []interface{}{
+				1,
+				"a",
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+		},
+	}
+
+	runOptimizerTests(t, tests)
+
+	// 이전 컴파일에서 이어받은 상수도 재사용한다.
+	first := New(WithOptimizations())
+	if err := first.Compile(parse(`let x = 5;`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	second := NewWithState(first.symbolTable, first.Bytecode().Constants, WithOptimizations())
+	if err := second.Compile(parse(`x + 5`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	if len(second.Bytecode().Constants) != 1 {
+		t.Errorf("constant not reused. got=%d constants", len(second.Bytecode().Constants))
+	}
+}
+
+func TestPeephole(t *testing.T) {
+	tests := []optimizerTestCase{
+		// 값을 넣자마자 꺼내는 쌍. 마지막 OpPop은 프로그램의 결과이므로 남긴다.
+		{
+			input: `let x = 1; x; 2; x`,
+			before: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+			after: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+			expectedConstants: []interface{}{1, 2},
+		},
+		// 함수 본문에서는 마지막 문장 앞의 쌍을 모두 지운다.
+		{
+			input: `fn(a) { a; 1; a }`,
+			before: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+			after: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+		},
+		// 점프로 가는 점프: 안쪽 if의 OpJump는 바깥 if의 끝으로 바로 간다.
+		{
+			input: `let a = true; let b = false; if (a) { if (b) { 1 } else { 2 } } else { 3 }`,
+			before: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpSetGlobal, 0),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpSetGlobal, 1),
+				// 0008
+				code.Make(code.OpGetGlobal, 0),
+				// 0011
+				code.Make(code.OpJumpNotTruthy, 32),
+				// 0014
+				code.Make(code.OpGetGlobal, 1),
+				// 0017
+				code.Make(code.OpJumpNotTruthy, 26),
+				// 0020
+				code.Make(code.OpConstant, 0),
+				// 0023
+				code.Make(code.OpJump, 29),
+				// 0026
+				code.Make(code.OpConstant, 1),
+				// 0029
+				code.Make(code.OpJump, 35),
+				// 0032
+				code.Make(code.OpConstant, 2),
+				// 0035
+				code.Make(code.OpPop),
+			},
+			after: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpSetGlobal, 0),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpSetGlobal, 1),
+				// 0008
+				code.Make(code.OpGetGlobal, 0),
+				// 0011
+				code.Make(code.OpJumpNotTruthy, 32),
+				// 0014
+				code.Make(code.OpGetGlobal, 1),
+				// 0017
+				code.Make(code.OpJumpNotTruthy, 26),
+				// 0020
+				code.Make(code.OpConstant, 0),
+				// 0023
+				code.Make(code.OpJump, 35),
+				// 0026
+				code.Make(code.OpConstant, 1),
+				// 0029
+				code.Make(code.OpJump, 35),
+				// 0032
+				code.Make(code.OpConstant, 2),
+				// 0035
+				code.Make(code.OpPop),
+			},
+			expectedConstants: []interface{}{1, 2, 3},
+		},
+		// 조건이 상수인 조건부 점프
+		{
+			input: `if (1 < 2) { 10 } else { 20 }; if (false) { 30 }`,
+			before: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpConstant, 1),
+				// 0006
+				code.Make(code.OpGreaterThan),
+				// 0007
+				code.Make(code.OpJumpNotTruthy, 16),
+				// 0010
+				code.Make(code.OpConstant, 2),
+				// 0013
+				code.Make(code.OpJump, 19),
+				// 0016
+				code.Make(code.OpConstant, 3),
+				// 0019
+				code.Make(code.OpPop),
+				// 0020
+				code.Make(code.OpFalse),
+				// 0021
+				code.Make(code.OpJumpNotTruthy, 30),
+				// 0024
+				code.Make(code.OpConstant, 4),
+				// 0027
+				code.Make(code.OpJump, 31),
+				// 0030
+				code.Make(code.OpNull),
+				// 0031
+				code.Make(code.OpPop),
+			},
+			after: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpJump, 9),
+				// 0006
+				code.Make(code.OpConstant, 1),
+				// 0009
+				code.Make(code.OpPop),
+				// 0010
+				code.Make(code.OpJump, 19),
+				// 0013
+				code.Make(code.OpConstant, 2),
+				// 0016
+				code.Make(code.OpJump, 20),
+				// 0019
+				code.Make(code.OpNull),
+				// 0020
+				code.Make(code.OpPop),
+			},
+			expectedConstants: []interface{}{10, 20, 30},
+		},
+	}
+
+	runOptimizerTests(t, tests)
+}
+
+/*
+runOptimizerTests - 최적화 없이 컴파일한 명령어(before)와 최적화한 명령어(after)를 모두 확인한다.
+상수는 최적화한 쪽만 확인한다.
+*/
+func runOptimizerTests(t *testing.T, tests []optimizerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		before := New()
+		err := before.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		err = testInstructions(tt.before, before.Bytecode().Instructions)
+		if err != nil {
+			t.Fatalf("%s: testInstructions before optimization failed: %s", tt.input, err)
+		}
+
+		after := New(WithOptimizations())
+		err = after.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := after.Bytecode()
+		err = testInstructions(tt.after, bytecode.Instructions)
+		if err != nil {
+			t.Fatalf("%s: testInstructions after optimization failed: %s", tt.input, err)
+		}
+		err = testConstants(t, tt.expectedConstants, bytecode.Constants)
+		if err != nil {
+			t.Fatalf("%s: testConstants failed: %s", tt.input, err)
+		}
+	}
+}
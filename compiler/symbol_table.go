@@ -0,0 +1,110 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+/*
+Symbol - 식별자(이름)에 대해 컴파일러가 알아야 하는 정보.
+Scope는 어디에 저장되는지를, Index는 해당 스코프 저장소에서의 위치를 나타낸다.
+*/
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+/*
+SymbolTable - 함수 본문마다 Outer를 감싸는 새 테이블을 만든다.
+Outer가 없는 테이블이 전역 스코프다.
+*/
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+
+	// 바깥 함수의 지역 바인딩 중 이 함수가 참조하는 것. 인덱스는 클로저의 Free 위치다.
+	FreeSymbols []Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol), FreeSymbols: []Symbol{}}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+/*
+Define - 식별자에 새로운 인덱스를 부여한다.
+같은 이름을 다시 정의하면 새로운 인덱스를 가진 심벌로 덮어쓴다.
+*/
+func (s *SymbolTable) Define(name string) Symbol {
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+/*
+DefineBuiltin - 내장 함수는 object.Builtins의 위치를 인덱스로 사용한다.
+*/
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Scope: BuiltinScope, Index: index}
+	s.store[name] = symbol
+	return symbol
+}
+
+/*
+DefineFunctionName - 함수 본문 안에서 let으로 바인딩된 자기 자신의 이름을 참조할 수 있게 한다.
+*/
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Scope: FunctionScope, Index: 0}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+/*
+Resolve - 현재 스코프에 없으면 바깥 스코프에서 찾는다.
+바깥 함수의 지역 바인딩(또는 자유 변수)이라면 이 함수의 자유 변수로 등록한다.
+*/
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
+
+	symbol, ok = s.Outer.Resolve(name)
+	if !ok {
+		return symbol, ok
+	}
+
+	if symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+		return symbol, ok
+	}
+
+	return s.defineFree(symbol), true
+}
@@ -1,17 +1,119 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"math/rand"
 	"monkey/ast"
 	"monkey/object"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
+// DefaultMaxDepth is the number of nested function calls after which
+// evaluation stops with a "maximum recursion depth exceeded" error, unless
+// the environment sets another limit with SetMaxDepth. Eval recurses on
+// the Go stack, which would otherwise overflow and crash the process.
+const DefaultMaxDepth = 10000
+
+// callStackSize is the number of innermost calls a recursion error lists.
+const callStackSize = 10
+
+// runtime lets builtins call back into functions of the evaluator. env is
+// the environment of the call, which supplies the Host.
+type runtime struct {
+	env *object.Environment
+}
+
+func (rt runtime) Call(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args, rt)
+}
+
+func (rt runtime) Host() *object.Host {
+	return rt.env.Host()
+}
+
+func (rt runtime) Limits() *object.Limits {
+	return rt.env.Limits()
+}
+
+func (rt runtime) Random() *rand.Rand {
+	return rt.env.Random()
+}
+
+// EvalContext evaluates node like Eval, but stops once ctx is done or the
+// program exceeds budget. It then returns an error wrapping
+// object.ErrCanceled, object.ErrStepLimitExceeded or
+// object.ErrAllocationLimitExceeded rather than an *object.Error.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, budget object.Budget) (object.Object, error) {
+	return WithLimits(env, object.NewLimits(ctx, budget), func() object.Object {
+		return Eval(node, env)
+	})
+}
+
+// WithLimits runs eval, which evaluates in env, under limits. Reaching a
+// limit may surface inside eval as an *object.Error, e.g. when a builtin
+// reports a failed callback, so it is reported as the limit's own error.
+func WithLimits(env *object.Environment, limits *object.Limits, eval func() object.Object) (object.Object, error) {
+	if limits == nil {
+		return eval(), nil
+	}
+	if err := limits.Check(); err != nil {
+		return nil, err
+	}
+
+	outer := env.Limits()
+	env.SetLimits(limits)
+	defer env.SetLimits(outer)
+
+	result := eval()
+	if err := limits.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// step counts a step of the evaluation in env against its limits.
+func step(env *object.Environment) *object.Error {
+	limits := env.Limits()
+	if limits == nil {
+		return nil
+	}
+	if err := limits.Step(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return nil
+}
+
+// maxDepth returns the number of nested calls env allows.
+func maxDepth(env *object.Environment) int {
+	if depth := env.MaxDepth(); depth > 0 {
+		return depth
+	}
+	return DefaultMaxDepth
+}
+
+// allocate counts obj, a value that was just created, against the
+// allocation limit of env.
+func allocate(env *object.Environment, obj object.Object) object.Object {
+	if object.SizeOf(obj) == 0 {
+		return obj
+	}
+	limits := env.Limits()
+	if limits == nil {
+		return obj
+	}
+	if err := limits.Alloc(obj); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return obj
+}
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
@@ -46,9 +148,15 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.InterpolatedString:
+		return allocate(env, evalInterpolatedString(node, env))
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
+	case *ast.Null:
+		return NULL
+
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 		if isError(right) {
@@ -62,12 +170,19 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return left
 		}
 
+		if node.Operator == "??" {
+			if left != NULL {
+				return left
+			}
+			return Eval(node.Right, env)
+		}
+
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 
-		return evalInfixExpression(node.Operator, left, right)
+		return allocate(env, evalInfixExpression(node.Operator, left, right))
 
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
@@ -78,7 +193,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Name: node.Name, Parameters: params, Env: env, Body: body}
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -91,14 +206,17 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		if fn, ok := function.(*object.Function); ok && node.Tail {
+			return &tailCall{fn: fn, args: args}
+		}
+		return applyFunction(function, args, runtime{env: env})
 
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return allocate(env, &object.Array{Elements: elements})
 
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
@@ -109,10 +227,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(index) {
 			return index
 		}
+		if node.Optional && left == NULL {
+			return NULL
+		}
 		return evalIndexExpression(left, index)
 
+	case *ast.SliceExpression:
+		return allocate(env, evalSliceExpression(node, env))
+
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return allocate(env, evalHashLiteral(node, env))
 
 	}
 
@@ -123,6 +247,9 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
+		if err := step(env); err != nil {
+			return err
+		}
 		result = Eval(statement, env)
 
 		switch result := result.(type) {
@@ -143,6 +270,9 @@ func evalBlockStatement(
 	var result object.Object
 
 	for _, statement := range block.Statements {
+		if err := step(env); err != nil {
+			return err
+		}
 		result = Eval(statement, env)
 
 		if result != nil {
@@ -184,9 +314,9 @@ func evalInfixExpression(
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equals(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equals(left, right))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -252,14 +382,37 @@ func evalStringInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
-	if operator != "+" {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
+}
 
-	leftVal := left.(*object.String).Value
-	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
+func evalInterpolatedString(
+	node *ast.InterpolatedString,
+	env *object.Environment,
+) object.Object {
+	var out bytes.Buffer
+
+	for _, part := range node.Parts {
+		evaluated := Eval(part, env)
+		if isError(evaluated) {
+			return evaluated
+		}
+		out.WriteString(evaluated.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
 }
 
 func evalIfExpression(
@@ -288,7 +441,7 @@ func evalIdentifier(
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin := object.GetBuiltinByName(node.Value); builtin != nil {
 		return builtin
 	}
 
@@ -336,27 +489,67 @@ func evalExpressions(
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// Apply calls fn, a function or builtin, with args. Builtins get the Host
+// of env; fn itself does not see env.
+func Apply(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	return applyFunction(fn, args, runtime{env: env})
+}
+
+func applyFunction(fn object.Object, args []object.Object, rt runtime) object.Object {
 	switch fn := fn.(type) {
 
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		// Tail calls in the body come back as a tailCall and are run in
+		// this loop, so tail recursion does not grow the Go stack.
+		for {
+			if err := step(rt.env); err != nil {
+				return err
+			}
+			if len(args) != len(fn.Parameters) {
+				return newError("wrong number of arguments: want=%d, got=%d",
+					len(fn.Parameters), len(args))
+			}
+			if rt.env.Depth() >= maxDepth(rt.env) {
+				return traceCall(&object.Error{Stack: []string{}}, fn)
+			}
+			extendedEnv := extendFunctionEnv(fn, args, rt.env)
+			evaluated := unwrapReturnValue(Eval(fn.Body, extendedEnv))
+			if call, ok := evaluated.(*tailCall); ok {
+				fn, args = call.fn, call.args
+				continue
+			}
+			if errObj, ok := evaluated.(*object.Error); ok && errObj.Stack != nil {
+				return traceCall(errObj, fn)
+			}
+			return evaluated
+		}
 
 	case *object.Builtin:
-		return fn.Fn(args...)
+		// builtins count the values they create themselves
+		return fn.Fn(rt, args...)
 
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// tailCall is what a call marked with ast.CallExpression.Tail evaluates
+// to: the function and arguments applyFunction calls next. It never
+// escapes applyFunction, as tail calls only occur in function bodies.
+type tailCall struct {
+	fn   *object.Function
+	args []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
+	caller *object.Environment,
 ) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+	env := object.NewCallEnvironment(fn.Env, caller)
 
 	for paramIdx, param := range fn.Parameters {
 		env.Set(param.Value, args[paramIdx])
@@ -365,6 +558,32 @@ func extendFunctionEnv(
 	return env
 }
 
+// traceCall adds the call of fn to the call stack of a recursion error as
+// the error returns through it. Only the innermost calls are listed.
+func traceCall(err *object.Error, fn *object.Function) *object.Error {
+	err.Stack = append(err.Stack, functionName(fn))
+
+	var out bytes.Buffer
+	out.WriteString("maximum recursion depth exceeded")
+	for i, name := range err.Stack {
+		if i == callStackSize {
+			fmt.Fprintf(&out, "\n\t... %d more", len(err.Stack)-callStackSize)
+			break
+		}
+		out.WriteString("\n\tin " + name)
+	}
+	err.Message = out.String()
+
+	return err
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Name
+}
+
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
 		return returnValue.Value
@@ -377,6 +596,8 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -387,22 +608,66 @@ func evalIndexExpression(left, index object.Object) object.Object {
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
 
-	if idx < 0 || idx > max {
+	element, ok := arrayObject.At(idx)
+	if !ok {
+		return NULL
+	}
+
+	return element
+}
+
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	stringObject := str.(*object.String)
+	idx := index.(*object.Integer).Value
+
+	char, ok := stringObject.At(idx)
+	if !ok {
 		return NULL
 	}
 
-	return arrayObject.Elements[idx]
+	return char
+}
+
+func evalSliceExpression(
+	node *ast.SliceExpression,
+	env *object.Environment,
+) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	bounds := []object.Object{NULL, NULL}
+	for i, bound := range []ast.Expression{node.Start, node.End} {
+		if bound == nil {
+			continue
+		}
+		bounds[i] = Eval(bound, env)
+		if isError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+
+	if node.Optional && left == NULL {
+		return NULL
+	}
+
+	result, err := object.Slice(left, bounds[0], bounds[1])
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	return result
 }
 
 func evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
@@ -413,16 +678,15 @@ func evalHashLiteral(
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
@@ -433,10 +697,10 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
 }
@@ -1,10 +1,16 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -221,6 +227,14 @@ if (10 > 1) {
 			`999[1]`,
 			"index operator not supported: INTEGER",
 		},
+		{
+			"fn(a, b) { a + b }(1)",
+			"wrong number of arguments: want=2, got=1",
+		},
+		{
+			"1()",
+			"not a function: INTEGER",
+		},
 	}
 
 	for _, tt := range tests {
@@ -342,6 +356,54 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestUnicodeStringLiteral(t *testing.T) {
+	input := `let 인사 = "안녕하세요"; 인사 + ", 세계!"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "안녕하세요, 세계!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = 1; let b = 2; "total: ${a + b}"`, "total: 3"},
+		{`"${true} and ${[1, "two"]}"`, "true and [1, two]"},
+		{`let name = "몽키"; "안녕, ${name}!"`, "안녕, 몽키!"},
+		{`"${"nested ${1 + 1}"}"`, "nested 2"},
+		{`"no interpolation: \${1}"`, "no interpolation: ${1}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. want=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+
+	evaluated := testEval(`"${1 + true}"`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -364,6 +426,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("한글")`, 2},
+		{`len("naïve 😀")`, 7},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		{`len([1, 2, 3])`, 3},
@@ -420,6 +484,303 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`let n = 10; map([1, 2], fn(x) { x + n })`, "[11, 12]"},
+		{`map([[1], [1, 2]], len)`, "[1, 2]"},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3, 4]"},
+		{`reduce([1, 2, 3, 4], 0, fn(acc, x) { acc + x })`, "10"},
+		{`reduce([], 5, fn(acc, x) { acc + x })`, "5"},
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, "[3, 2, 1]"},
+		{`sort([[2, "a"], [1, "b"], [2, "c"]], fn(a, b) { a[0] < b[0] })`, "[[1, b], [2, a], [2, c]]"},
+		{`let a = [2, 1]; sort(a); a`, "[2, 1]"},
+		{`reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`reverse("한글")`, "글한"},
+		{`contains([1, [2]], [2])`, "true"},
+		{`contains([1, 2], 3)`, "false"},
+		{`index_of(["a", "b"], "b")`, "1"},
+		{`index_of([], 1)`, "-1"},
+		{`range(3)`, "[0, 1, 2]"},
+		{`range(1, 4)`, "[1, 2, 3]"},
+		{`range(10, 0, -3)`, "[10, 7, 4, 1]"},
+		{`range(3, 1)`, "[]"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`keys({"a": 1, "b": 2})`, "[a, b]"},
+		{`values({"a": 1, "b": 2})`, "[1, 2]"},
+		{`has_key({"a": 1}, "a")`, "true"},
+		{`has_key({"a": 1}, "b")`, "false"},
+		{`delete({"a": 1, "b": 2}, "a")`, "{b: 2}"},
+		{`let h = {"a": 1}; delete(h, "a"); h`, "{a: 1}"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, "{a: 1, b: 3, c: 4}"},
+		{`map([1, 2], fn(x) { reduce(range(x + 1), 0, fn(a, b) { a + b }) })`, "[1, 3]"},
+
+		{`map([1], fn(x, y) { x })`, "wrong number of arguments: want=2, got=1"},
+		{`map([1, 2], fn(x) { -"a" })`, "unknown operator: -STRING"},
+		{`filter(1, len)`, "argument to `filter` must be ARRAY, got INTEGER"},
+		{`map([], 1)`, "callback of `map` must be a function, got INTEGER"},
+		{`reduce([1], 0)`, "wrong number of arguments. got=2, want=3"},
+		{`sort([1, "a"])`, "cannot compare INTEGER and STRING in `sort`"},
+		{`sort([2, 1], fn(a, b) { a + "x" })`, "type mismatch: INTEGER + STRING"},
+		{`range("a")`, "argument to `range` must be INTEGER, got STRING"},
+		{`range(1, 2, 0)`, "step of `range` must not be zero"},
+		{`keys([])`, "argument to `keys` must be HASH, got ARRAY"},
+		{`has_key({}, {})`, "unusable as hash key: HASH"},
+		{`merge({}, 1)`, "argument to `merge` must be HASH, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("wrong result for %s. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split("a,b,c", ",")`, "[a, b, c]"},
+		{`split("한글", "")`, "[한, 글]"},
+		{`split("abc", "x")`, "[abc]"},
+		{`join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`join([], ",")`, ""},
+		{`join(split("a b", " "), "_")`, "a_b"},
+		{`trim("  hi \n")`, "hi"},
+		{`upper("monkey")`, "MONKEY"},
+		{`lower("MoNkEy")`, "monkey"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`starts_with("monkey", "mon")`, "true"},
+		{`starts_with("monkey", "key")`, "false"},
+		{`ends_with("monkey", "key")`, "true"},
+		{`contains("monkey", "nk")`, "true"},
+		{`contains("monkey", "x")`, "false"},
+		{`find("monkey", "key")`, "3"},
+		{`find("한글 monkey", "monkey")`, "3"},
+		{`find("monkey", "x")`, "-1"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 0)`, ""},
+		{`ord("a")`, "97"},
+		{`ord("한")`, "54620"},
+		{`chr(97)`, "a"},
+		{`chr(ord("가") + 1)`, "각"},
+		{`str(5)`, "5"},
+		{`str([1, "a"])`, "[1, a]"},
+		{`str("a")`, "a"},
+		{`int("42")`, "42"},
+		{`int(" -7 ")`, "-7"},
+		{`int(5)`, "5"},
+		{`int("42") + 1`, "43"},
+		{`str(1) + str(2)`, "12"},
+
+		{`split("a", 1)`, "argument to `split` must be STRING, got INTEGER"},
+		{`join([1, 2], ",")`, "elements joined by `join` must be STRING, got INTEGER"},
+		{`join("a", ",")`, "argument to `join` must be ARRAY, got STRING"},
+		{`upper(1)`, "argument to `upper` must be STRING, got INTEGER"},
+		{`trim("a", "b")`, "wrong number of arguments. got=2, want=1"},
+		{`replace("a", "b")`, "wrong number of arguments. got=2, want=3"},
+		{`contains("a", 1)`, "argument to `contains` must be STRING, got INTEGER"},
+		{`contains(1, 1)`, "argument to `contains` not supported, got INTEGER"},
+		{`repeat("a", -1)`, "count of `repeat` must not be negative, got -1"},
+		{`ord("ab")`, "argument to `ord` must be a single character, got \"ab\""},
+		{`chr(-1)`, "invalid code point: -1"},
+		{`chr(55296)`, "invalid code point: 55296"},
+		{`int("4x2")`, "could not parse \"4x2\" as integer"},
+		{`int("")`, "could not parse \"\" as integer"},
+		{`int(true)`, "argument to `int` not supported, got BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("wrong result for %s. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`abs(-5)`, "5"},
+		{`abs(5)`, "5"},
+		{`min(3, 1, 2)`, "1"},
+		{`max(3, 1, 2)`, "3"},
+		{`min([4, -2])`, "-2"},
+		{`max(7)`, "7"},
+		{`pow(2, 10)`, "1024"},
+		{`pow(-3, 3)`, "-27"},
+		{`pow(5, 0)`, "1"},
+		{`sqrt(16)`, "4"},
+		{`sqrt(17)`, "4"},
+		{`sqrt(0)`, "0"},
+		{`sqrt(9223372036854775807)`, "3037000499"},
+		{`clamp(5, 0, 3)`, "3"},
+		{`clamp(-5, 0, 3)`, "0"},
+		{`clamp(2, 0, 3)`, "2"},
+		{`gcd(12, 18)`, "6"},
+		{`gcd(-12, 18)`, "6"},
+		{`gcd(0, 0)`, "0"},
+		{`seed(7); let a = random(1000); seed(7); a == random(1000)`, "true"},
+		{`seed(7); let r = map(range(20), fn(i) { random(5, 8) }); filter(r, fn(x) { if (x < 5) { true } else { x > 7 } })`, "[]"},
+
+		{`abs()`, "wrong number of arguments. got=0, want=1"},
+		{`abs("a")`, "argument to `abs` must be INTEGER, got STRING"},
+		{`abs(-9223372036854775807 - 1)`, "integer overflow in `abs`"},
+		{`min()`, "wrong number of arguments. got=0, want=1 or more"},
+		{`max([])`, "argument to `max` must not be empty"},
+		{`max(1, "2")`, "argument to `max` must be INTEGER, got STRING"},
+		{`pow(2, -1)`, "exponent of `pow` must not be negative, got -1"},
+		{`pow(2, 64)`, "integer overflow in `pow`"},
+		{`sqrt(-1)`, "argument to `sqrt` must not be negative, got -1"},
+		{`clamp(1, 3, 0)`, "bounds of `clamp` are reversed: 3 > 0"},
+		{`gcd(1)`, "wrong number of arguments. got=1, want=2"},
+		{`random(0)`, "range of `random` is empty: [0, 0)"},
+		{`random(1, 2, 3)`, "wrong number of arguments. got=3, want=1 or 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("wrong result for %s. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestTypeBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`type(1)`, "INTEGER"},
+		{`type("a")`, "STRING"},
+		{`type(true)`, "BOOLEAN"},
+		{`type(null)`, "NULL"},
+		{`type([])`, "ARRAY"},
+		{`type({})`, "HASH"},
+		{`type(fn(x) { x })`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`is_int(1)`, "true"},
+		{`is_int("1")`, "false"},
+		{`is_string("a")`, "true"},
+		{`is_bool(false)`, "true"},
+		{`is_array([1])`, "true"},
+		{`is_hash({})`, "true"},
+		{`is_null(null)`, "true"},
+		{`is_null(0)`, "false"},
+		{`is_function(fn() { 1 })`, "true"},
+		{`is_function(len)`, "true"},
+		{`is_function([])`, "false"},
+		{`to_int("42")`, "42"},
+		{`to_int(true)`, "1"},
+		{`to_int(false)`, "0"},
+		{`to_int(7)`, "7"},
+		{`to_string(42)`, "42"},
+		{`to_string([1, "a"])`, "[1, a]"},
+		{`to_bool(0)`, "true"},
+		{`to_bool(null)`, "false"},
+		{`to_bool(false)`, "false"},
+		{`to_bool("")`, "true"},
+		{`to_array("한글")`, "[한, 글]"},
+		{`to_array({"a": 1, "b": 2})`, "[[a, 1], [b, 2]]"},
+		{`to_array(null)`, "[]"},
+		{`to_array([1])`, "[1]"},
+		{`map([1, "a", null], type)`, "[INTEGER, STRING, NULL]"},
+
+		{`type()`, "wrong number of arguments. got=0, want=1"},
+		{`is_int(1, 2)`, "wrong number of arguments. got=2, want=1"},
+		{`to_int("x")`, "could not parse \"x\" as integer"},
+		{`to_int([])`, "cannot convert ARRAY to INTEGER"},
+		{`to_int(fn() { 1 })`, "cannot convert FUNCTION to INTEGER"},
+		{`to_array(1)`, "cannot convert INTEGER to ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("wrong result for %s. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_encode(1)`, "1"},
+		{`json_encode("a\"b")`, `"a\"b"`},
+		{`json_encode("<한>")`, `"<한>"`},
+		{`json_encode(null)`, "null"},
+		{`json_encode([1, true, null, "x"])`, `[1,true,null,"x"]`},
+		{`json_encode({"b": 1, "a": [2]})`, `{"b":1,"a":[2]}`},
+		{`json_encode({})`, "{}"},
+		{`json_encode({"a": 1, "b": [1, 2], "c": {}}, true)`, "{\n  \"a\": 1,\n  \"b\": [\n    1,\n    2\n  ],\n  \"c\": {}\n}"},
+		{`json_decode("{\"b\": 1, \"a\": [true, null, \"x\"]}")`, "{b: 1, a: [true, null, x]}"},
+		{`json_decode("[]")`, "[]"},
+		{`json_decode("-12")`, "-12"},
+		{`json_decode("\"\\u00e9\"")`, "é"},
+		{`json_decode("{\"a\": 1, \"a\": 2}")`, "{a: 2}"},
+		{`json_decode("{\"n\": 5}")["n"] + 1`, "6"},
+		{`let v = {"a": [1, {"b": null}], "c": "d"}; json_decode(json_encode(v)) == v`, "true"},
+		{`json_decode(json_encode({"a": 1}, true))`, "{a: 1}"},
+
+		{`json_encode()`, "wrong number of arguments. got=0, want=1 or 2"},
+		{`json_encode(1, 1)`, "argument to `json_encode` must be BOOLEAN, got INTEGER"},
+		{`json_encode(fn() { 1 })`, "json_encode: cannot encode FUNCTION at $"},
+		{`json_encode({"a": [1, len]})`, "json_encode: cannot encode BUILTIN at $.a[1]"},
+		{`json_encode({1: 2})`, "json_encode: cannot encode INTEGER key 1 at $, keys must be STRING"},
+		{`json_decode(1)`, "argument to `json_decode` must be STRING, got INTEGER"},
+		{`json_decode("[1,")`, "json_decode: unexpected end of JSON input"},
+		{`json_decode("[1")`, "json_decode: unexpected end of JSON input"},
+		{`json_decode("")`, "json_decode: unexpected end of JSON input"},
+		{`json_decode("1 2")`, "json_decode: unexpected data after JSON value"},
+		{`json_decode("{\"a\" 1}")`, "json_decode: invalid character '1' after object key"},
+		{`json_decode("1.5")`, "json_decode: number 1.5 is not an integer"},
+		{`json_decode("99999999999999999999")`, "json_decode: number 99999999999999999999 is out of range"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("wrong result for %s. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -482,6 +843,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -497,6 +866,87 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"monkey"[0]`, "m"},
+		{`"monkey"[5]`, "y"},
+		{`"monkey"[-1]`, "y"},
+		{`"한글"[1]`, "글"},
+		{`"naïve"[2]`, "ï"},
+		{`"monkey"[6]`, nil},
+		{`"monkey"[-7]`, nil},
+		{`""[0]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		expected, ok := tt.expected.(string)
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != expected {
+			t.Errorf("String has wrong value. want=%q, got=%q", expected, str.Value)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][-100:100]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][3:1]", "[]"},
+		{"let a = [1, 2, 3]; let i = 1; a[i:i + 1]", "[2]"},
+		{`"monkey"[1:3]`, "on"},
+		{`"monkey"[:-3]`, "mon"},
+		{`"monkey"[3:]`, "key"},
+		{`"안녕하세요"[1:3]`, "녕하"},
+		{`null?[1:2]`, nil},
+		{`[1, 2]?[1:]`, "[2]"},
+		{`1[0:1]`, "slice operator not supported: INTEGER"},
+		{`[1, 2]["a":]`, "slice bound must be INTEGER, got STRING"},
+		{`[1, 2][:foo]`, "identifier not found: foo"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if tt.expected == nil {
+			testNullObject(t, evaluated)
+			continue
+		}
+
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("wrong result for %s. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+
+	array := testEval("let a = [1, 2, 3]; let b = a[:]; a")
+	if array.Inspect() != "[1, 2, 3]" {
+		t.Errorf("slicing modified the original array. got=%s", array.Inspect())
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -514,26 +964,104 @@ func TestHashLiterals(t *testing.T) {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
-		if !ok {
-			t.Errorf("no pair for given key in Pairs")
+	for i, pair := range result.Pairs() {
+		hashKey, ok := pair.Key.(object.Hashable)
+		if !ok || hashKey.HashKey() != expected[i].key.HashKey() {
+			t.Errorf("pair %d has wrong key. want=%s, got=%s",
+				i, expected[i].key.Inspect(), pair.Key.Inspect())
+		}
+
+		testIntegerObject(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, "c": 3}`, "{b: 1, a: 2, c: 3}"},
+		{`{3: "x", 1: "y", 2: "z"}`, "{3: x, 1: y, 2: z}"},
+		{`{"a": 1, "b": 2, "a": 3}`, "{a: 3, b: 2}"},
+		{`{true: 1, "k": {"z": 1, "y": 2}}`, "{true: 1, k: {z: 1, y: 2}}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect() output. want=%q, got=%q",
+				tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{`[1, 2] == [1, 2]`, true},
+		{`[1, 2] != [1, 2]`, false},
+		{`[1, 2] == [2, 1]`, false},
+		{`[1, [2, "x"]] == [1, [2, "x"]]`, true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`1 == "1"`, false},
+		{`[] == {}`, false},
+		{`null == [null][0]`, true},
+		{`let f = fn() { 1 }; f == f`, true},
+		{`fn() { 1 } == fn() { 1 }`, false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestArrayHashKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{[1, 2]: "a"}[[1, 2]]`, "a"},
+		{`{[1, 2]: "a"}[[2, 1]]`, nil},
+		{`{[1, [2]]: "a", [1, 2]: "b"}[[1, [2]]]`, "a"},
+		{`{[]: "empty"}[[]]`, "empty"},
+		{`let key = ["x", true]; {key: "a"}[["x", true]]`, "a"},
+		{`{[1]: "a", [1]: "b"}`, "{[1]: b}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if tt.expected == nil {
+			testNullObject(t, evaluated)
+			continue
 		}
 
-		testIntegerObject(t, pair.Value, expectedValue)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
 	}
 }
 
@@ -582,6 +1110,50 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+func TestNullAndOptionalChaining(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"null", nil},
+		{"!null", true},
+		{"null == null", true},
+		{"null ?? 5", 5},
+		{"1 ?? 5", 1},
+		{"false ?? 5", false},
+		{"null ?? null", nil},
+		{"null ?? null ?? 3", 3},
+		{`let h = {"a": {"b": 1}}; h?["a"]?["b"]`, 1},
+		{`let h = {"a": {"b": 1}}; h?["x"]?["b"]`, nil},
+		{`let h = {"a": {"b": 1}}; h?["x"]?["b"] ?? 42`, 42},
+		{`null?[0]`, nil},
+		{`[1, 2]?[1]`, 2},
+		{`1 ?? foobar`, 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	evaluated := testEval(`let h = {}; h["x"]["b"]`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "index operator not supported: NULL" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -627,3 +1199,168 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 	}
 	return true
 }
+
+func TestHost(t *testing.T) {
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	env.SetHost(&object.Host{Stdout: &out, Args: []string{"x"}})
+
+	l := lexer.New(`let f = fn() { puts(map(args(), fn(a) { a + "!" })) }; f()`)
+	p := parser.New(l)
+	Eval(p.ParseProgram(), env)
+
+	if out.String() != "[x!]\n" {
+		t.Errorf("puts wrote %q", out.String())
+	}
+
+	evaluated := testEval(`args()`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "args: reading arguments is not permitted" {
+		t.Errorf("expected args to be denied without a host, got=%+v", evaluated)
+	}
+}
+
+func TestExecutionLimits(t *testing.T) {
+	countdown := `let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(500)`
+	busy := `reduce(range(3000), 0, fn(acc, x) { reduce(range(3000), acc, fn(a, y) { a + 1 }) })`
+
+	eval := func(ctx context.Context, input string, maxSteps int64) (object.Object, error) {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		return EvalContext(ctx, program, object.NewEnvironment(), object.Budget{MaxSteps: maxSteps})
+	}
+
+	result, err := eval(context.Background(), countdown, 0)
+	if err != nil {
+		t.Fatalf("unlimited evaluation failed: %s", err)
+	}
+	testIntegerObject(t, result, 0)
+
+	_, err = eval(context.Background(), countdown, 100)
+	if !errors.Is(err, object.ErrStepLimitExceeded) {
+		t.Errorf("expected step limit error, got=%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = eval(ctx, countdown, 0)
+	if !errors.Is(err, object.ErrCanceled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error, got=%v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = eval(ctx, busy, 0)
+	if !errors.Is(err, object.ErrCanceled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got=%v", err)
+	}
+
+	// The limits are removed from env once the evaluation ends.
+	env := object.NewEnvironment()
+	program := parser.New(lexer.New(countdown)).ParseProgram()
+	_, err = EvalContext(context.Background(), program, env, object.Budget{MaxSteps: 100})
+	if !errors.Is(err, object.ErrStepLimitExceeded) {
+		t.Errorf("expected step limit error, got=%v", err)
+	}
+	testIntegerObject(t, Eval(program, env), 0)
+}
+
+func TestAllocationLimits(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{`let grow = fn(arr, n) { if (n == 0) { arr } else { grow(push(arr, "x"), n - 1) } }; len(grow([], 500))`,
+			object.ErrAllocationLimitExceeded},
+		{`let double = fn(s, n) { if (n == 0) { s } else { double(s + s, n - 1) } }; len(double("x", 40))`,
+			object.ErrAllocationLimitExceeded},
+		{`len("${[1, 2, 3]}" + repeat("x", 1000000000000))`, object.ErrAllocationLimitExceeded},
+		{`map(range(1000000000000000), fn(x) { x })`, object.ErrAllocationLimitExceeded},
+		{`let grow = fn(arr, n) { if (n == 0) { arr } else { grow(push(arr, "x"), n - 1) } }; len(grow([], 50))`,
+			nil},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		_, err := EvalContext(context.Background(), program, object.NewEnvironment(),
+			object.Budget{MaxAllocated: 100000})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("wrong error for %s. want=%v, got=%v", tt.input, tt.err, err)
+		}
+	}
+}
+
+func TestRecursionDepthLimit(t *testing.T) {
+	input := `
+	let countdown = fn(n) { if (n == 0) { 0 } else { 1 + countdown(n - 1) } };
+	let start = fn(n) { let result = countdown(n); result };
+	start(-1)
+	`
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := "maximum recursion depth exceeded" + strings.Repeat("\n\tin countdown", callStackSize) +
+		fmt.Sprintf("\n\t... %d more", DefaultMaxDepth+1-callStackSize)
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+	if len(errObj.Stack) != DefaultMaxDepth+1 || errObj.Stack[DefaultMaxDepth] != "start" {
+		t.Errorf("wrong call stack. len=%d", len(errObj.Stack))
+	}
+
+	// The depth counts calls made by builtins as well.
+	env := object.NewEnvironment()
+	env.SetMaxDepth(3)
+	program := parser.New(lexer.New(`let f = fn(x) { map([x], fn(y) { let r = f(y); r }) }; f(1)`)).ParseProgram()
+	evaluated = Eval(program, env)
+	errObj, ok = evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	expected = "maximum recursion depth exceeded\n\tin <anonymous>\n\tin f\n\tin <anonymous>\n\tin f"
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+
+	testIntegerObject(t, testEval(`let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(2)`), 0)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let countdown = fn(n) { if (n == 0) { 0 } else { countdown(n - 1) } }; countdown(1000000)`, 0},
+		{`let sum = fn(n, acc) { if (n == 0) { return acc; } return sum(n - 1, acc + n); }; sum(100000, 0)`, 5000050000},
+		{`
+		let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+		let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+		even(100001)
+		`, false},
+		{`let loop = fn(n) { if (n == 0) { len("abc") } else { loop(n - 1) } }; loop(100000)`, 3},
+		{`let f = fn(x) { x }; let g = fn() { f(1, 2) }; g()`, "wrong number of arguments: want=1, got=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("wrong result for %s. want=%q, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+
+	// Calls that are not in tail position still count towards the depth.
+	evaluated := testEval(`let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(100000)`)
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Stack == nil {
+		t.Errorf("expected recursion depth error, got=%s", evaluated.Inspect())
+	}
+}
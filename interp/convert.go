@@ -0,0 +1,551 @@
+package interp
+
+import (
+	"fmt"
+	"math"
+	"monkey/object"
+	"reflect"
+	"sort"
+)
+
+// Values are converted between Go and Monkey as follows:
+//
+//	bool                       BOOLEAN
+//	signed and unsigned ints   INTEGER
+//	string                     STRING
+//	slices and arrays          ARRAY
+//	maps                       HASH, with keys sorted
+//	structs                    HASH with a STRING key per exported field
+//	nil pointers, slices, ...  NULL
+//	funcs                      BUILTIN, see Func
+//
+// Struct fields can be renamed with a `monkey:"name"` tag and skipped with
+// `monkey:"-"`. Values that already are an object.Object are used as they
+// are, so Go code can pass Monkey functions through untouched.
+
+var (
+	objectType  = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	runtimeType = reflect.TypeOf((*object.Runtime)(nil)).Elem()
+)
+
+// ToObject converts a Go value to a Monkey value.
+func ToObject(v any) (object.Object, error) {
+	c := &converter{visiting: make(map[visit]bool)}
+	return c.toObject(reflect.ValueOf(v))
+}
+
+// FromObject stores obj in the value target points to, converting it to
+// the type of that value. An `any` target receives int64, string, bool,
+// []any, map[string]any, map[any]any for hashes with other keys, or nil.
+func FromObject(obj object.Object, target any) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+	return fromObject(obj, ptr.Elem())
+}
+
+type converter struct {
+	// pointers, maps and slices being converted, to detect cycles
+	visiting map[visit]bool
+}
+
+// visit identifies a value that may refer back to itself. Slices also
+// need their length, as a slice and its prefixes share a data pointer.
+type visit struct {
+	ptr    uintptr
+	length int
+	typ    reflect.Type
+}
+
+func (c *converter) toObject(v reflect.Value) (object.Object, error) {
+	if !v.IsValid() {
+		return object.NULL, nil
+	}
+	if v.Type().Implements(objectType) {
+		if isNil(v) {
+			return object.NULL, nil
+		}
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return object.TRUE, nil
+		}
+		return object.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows INTEGER", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Interface:
+		return c.toObject(v.Elem())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+		if err := c.enter(v); err != nil {
+			return nil, err
+		}
+		defer c.leave(v)
+		return c.toObject(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return object.NULL, nil
+		}
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			if err := c.enter(v); err != nil {
+				return nil, err
+			}
+			defer c.leave(v)
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			el, err := c.toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+		if err := c.enter(v); err != nil {
+			return nil, err
+		}
+		defer c.leave(v)
+		return c.mapToHash(v)
+	case reflect.Struct:
+		return c.structToHash(v)
+	case reflect.Func:
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+		return Func("func", v.Interface())
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a Monkey value", v.Type())
+}
+
+func (c *converter) enter(v reflect.Value) error {
+	key := visitOf(v)
+	if c.visiting[key] {
+		return fmt.Errorf("cannot convert cyclic value of type %s", v.Type())
+	}
+	c.visiting[key] = true
+	return nil
+}
+
+func (c *converter) leave(v reflect.Value) {
+	delete(c.visiting, visitOf(v))
+}
+
+func visitOf(v reflect.Value) visit {
+	key := visit{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.length = v.Len()
+	}
+	return key
+}
+
+// mapToHash sorts the keys, so that the hash does not depend on Go's
+// random map order.
+func (c *converter) mapToHash(v reflect.Value) (object.Object, error) {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return lessKey(keys[i], keys[j])
+	})
+
+	hash := object.NewHash()
+	for _, key := range keys {
+		k, err := c.toObject(key)
+		if err != nil {
+			return nil, err
+		}
+		hashable, ok := k.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", k.Type())
+		}
+		value, err := c.toObject(v.MapIndex(key))
+		if err != nil {
+			return nil, err
+		}
+		hash.Set(hashable, value)
+	}
+	return hash, nil
+}
+
+func lessKey(a, b reflect.Value) bool {
+	for a.Kind() == reflect.Interface || a.Kind() == reflect.Pointer {
+		if a.IsNil() {
+			break
+		}
+		a = a.Elem()
+	}
+	for b.Kind() == reflect.Interface || b.Kind() == reflect.Pointer {
+		if b.IsNil() {
+			break
+		}
+		b = b.Elem()
+	}
+	if a.Kind() != b.Kind() {
+		return a.Kind() < b.Kind()
+	}
+
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	default:
+		return fmt.Sprint(a) < fmt.Sprint(b)
+	}
+}
+
+func (c *converter) structToHash(v reflect.Value) (object.Object, error) {
+	hash := object.NewHash()
+	for _, field := range structFields(v.Type()) {
+		value, err := c.toObject(v.FieldByIndex(field.index))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.name, err)
+		}
+		hash.Set(&object.String{Value: field.name}, value)
+	}
+	return hash, nil
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields returns the exported fields of t under their Monkey names.
+func structFields(t reflect.Type) []structField {
+	fields := []structField{}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("monkey"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, structField{name: name, index: f.Index})
+	}
+	return fields
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+func fromObject(obj object.Object, v reflect.Value) error {
+	t := v.Type()
+
+	// Monkey values themselves, e.g. an object.Object or *object.Hash.
+	if t.Kind() != reflect.Interface || t.NumMethod() > 0 {
+		if reflect.TypeOf(obj).AssignableTo(t) {
+			v.Set(reflect.ValueOf(obj))
+			return nil
+		}
+	}
+
+	if obj == object.NULL {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func:
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+	}
+
+	mismatch := fmt.Errorf("cannot convert %s to %s", typeName(obj), t)
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return mismatch
+		}
+		v.SetBool(b.Value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch
+		}
+		if v.OverflowInt(i.Value) {
+			return fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		v.SetInt(i.Value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch
+		}
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		v.SetUint(uint64(i.Value))
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return mismatch
+		}
+		v.SetString(s.Value)
+	case reflect.Pointer:
+		elem := reflect.New(t.Elem())
+		if err := fromObject(obj, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Slice:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch
+		}
+		slice := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		for i, el := range arr.Elements {
+			if err := fromObject(el, slice.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		v.Set(slice)
+	case reflect.Array:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch
+		}
+		if len(arr.Elements) != t.Len() {
+			return fmt.Errorf("cannot convert ARRAY of length %d to %s", len(arr.Elements), t)
+		}
+		for i, el := range arr.Elements {
+			if err := fromObject(el, v.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch
+		}
+		m := reflect.MakeMapWithSize(t, hash.Len())
+		for _, pair := range hash.Pairs() {
+			key := reflect.New(t.Key()).Elem()
+			if err := fromObject(pair.Key, key); err != nil {
+				return fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			value := reflect.New(t.Elem()).Elem()
+			if err := fromObject(pair.Value, value); err != nil {
+				return fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch
+		}
+		for _, field := range structFields(t) {
+			value, ok := hash.Get(&object.String{Value: field.name})
+			if !ok {
+				continue
+			}
+			if err := fromObject(value, v.FieldByIndex(field.index)); err != nil {
+				return fmt.Errorf("field %s: %w", field.name, err)
+			}
+		}
+	case reflect.Interface:
+		if t.NumMethod() > 0 {
+			return mismatch
+		}
+		native, err := toNative(obj)
+		if err != nil {
+			return err
+		}
+		if native == nil {
+			v.Set(reflect.Zero(t))
+		} else {
+			v.Set(reflect.ValueOf(native))
+		}
+	default:
+		return mismatch
+	}
+
+	return nil
+}
+
+// toNative converts obj to the Go value an `any` receives. Functions stay
+// Monkey values.
+func toNative(obj object.Object) (any, error) {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		elements := make([]any, len(obj.Elements))
+		for i, el := range obj.Elements {
+			native, err := toNative(el)
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			elements[i] = native
+		}
+		return elements, nil
+	case *object.Hash:
+		var m reflect.Value
+		if allStringKeys(obj) {
+			m = reflect.ValueOf(make(map[string]any, obj.Len()))
+		} else {
+			m = reflect.ValueOf(make(map[any]any, obj.Len()))
+		}
+		for _, pair := range obj.Pairs() {
+			key, err := toNative(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			if key != nil && !reflect.TypeOf(key).Comparable() {
+				return nil, fmt.Errorf("cannot convert %s key %s to a Go map key",
+					typeName(pair.Key), pair.Key.Inspect())
+			}
+			value, err := toNative(pair.Value)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			m.SetMapIndex(nativeValue(key, m.Type().Key()), nativeValue(value, m.Type().Elem()))
+		}
+		return m.Interface(), nil
+	default:
+		return obj, nil
+	}
+}
+
+func nativeValue(v any, t reflect.Type) reflect.Value {
+	if v == nil {
+		return reflect.Zero(t)
+	}
+	return reflect.ValueOf(v)
+}
+
+func allStringKeys(hash *object.Hash) bool {
+	for _, pair := range hash.Pairs() {
+		if _, ok := pair.Key.(*object.String); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// typeName names functions FUNCTION in both engines, as the type builtin
+// does.
+func typeName(obj object.Object) string {
+	switch obj.Type() {
+	case object.CLOSURE_OBJ, object.COMPILED_FUNCTION_OBJ:
+		return string(object.FUNCTION_OBJ)
+	default:
+		return string(obj.Type())
+	}
+}
+
+// Func wraps the Go function fn as a builtin called name in error
+// messages. Arguments are converted with FromObject and the result with
+// ToObject. fn may take an object.Runtime as its first parameter to call
+// back into Monkey, may be variadic, and may return nothing, a value, an
+// error, or a value and an error. A non-nil error becomes an *object.Error.
+func Func(name string, fn any) (*object.Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("%s: cannot wrap %T, want a non-nil func", name, fn)
+	}
+	t := v.Type()
+
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	numValues := t.NumOut()
+	if returnsError {
+		numValues--
+	}
+	if numValues > 1 {
+		return nil, fmt.Errorf("%s: cannot wrap %s, want at most one result besides an error", name, t)
+	}
+
+	firstArg := 0
+	if t.NumIn() > 0 && t.In(0) == runtimeType {
+		firstArg = 1
+	}
+	numParams := t.NumIn() - firstArg
+
+	call := func(rt object.Runtime, args ...object.Object) object.Object {
+		if t.IsVariadic() && len(args) < numParams-1 {
+			return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want at least %d",
+				len(args), numParams-1)}
+		}
+		if !t.IsVariadic() && len(args) != numParams {
+			return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%d",
+				len(args), numParams)}
+		}
+
+		in := make([]reflect.Value, 0, firstArg+len(args))
+		if firstArg == 1 {
+			in = append(in, reflect.ValueOf(&rt).Elem())
+		}
+		for i, arg := range args {
+			var paramType reflect.Type
+			if t.IsVariadic() && firstArg+i >= t.NumIn()-1 {
+				paramType = t.In(t.NumIn() - 1).Elem()
+			} else {
+				paramType = t.In(firstArg + i)
+			}
+
+			param := reflect.New(paramType).Elem()
+			if err := fromObject(arg, param); err != nil {
+				return &object.Error{Message: fmt.Sprintf("argument %d to `%s`: %s", i+1, name, err)}
+			}
+			in = append(in, param)
+		}
+
+		out := v.Call(in)
+
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+		}
+		if numValues == 0 {
+			return object.NULL
+		}
+
+		result, err := ToObject(out[0].Interface())
+		if err != nil {
+			return &object.Error{Message: fmt.Sprintf("result of `%s`: %s", name, err)}
+		}
+		return result
+	}
+
+	return &object.Builtin{Fn: call}, nil
+}
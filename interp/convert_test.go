@@ -0,0 +1,286 @@
+package interp
+
+import (
+	"errors"
+	"monkey/object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	City string
+	Zip  string `monkey:"zip_code"`
+}
+
+type person struct {
+	Name    string
+	Age     int
+	Admin   bool `monkey:"is_admin"`
+	Tags    []string
+	Home    *address
+	Scores  map[string]int
+	Secret  string `monkey:"-"`
+	private int
+}
+
+func TestToObject(t *testing.T) {
+	answer := 42
+	shared := []any{1}
+
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{-7, "-7"},
+		{uint8(200), "200"},
+		{&answer, "42"},
+		{"monkey", "monkey"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{[]any{1, "a", nil}, "[1, a, null]"},
+		{[]int(nil), "null"},
+		{[]any{shared, shared, shared[:0]}, "[[1], [1], []]"},
+		{map[string]int{"b": 2, "a": 1, "c": 3}, "{a: 1, b: 2, c: 3}"},
+		{map[int]string{10: "x", -1: "y"}, "{-1: y, 10: x}"},
+		{&object.Integer{Value: 5}, "5"},
+		{
+			person{
+				Name:   "Ada",
+				Age:    36,
+				Admin:  true,
+				Tags:   []string{"x"},
+				Home:   &address{City: "London", Zip: "N1"},
+				Scores: map[string]int{"go": 1},
+				Secret: "hidden",
+			},
+			"{Name: Ada, Age: 36, is_admin: true, Tags: [x], Home: {City: London, zip_code: N1}, Scores: {go: 1}}",
+		},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("ToObject(%#v) failed: %s", tt.input, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func TestToObjectErrors(t *testing.T) {
+	type node struct {
+		Next *node
+	}
+	cyclic := &node{}
+	cyclic.Next = cyclic
+	cyclicSlice := []any{nil, 1}
+	cyclicSlice[0] = cyclicSlice
+
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{uint64(1 << 63), "9223372036854775808 overflows INTEGER"},
+		{1.5, "cannot convert float64 to a Monkey value"},
+		{cyclic, "field Next: cannot convert cyclic value of type *interp.node"},
+		{cyclicSlice, "cannot convert cyclic value of type []interface {}"},
+	}
+
+	for _, tt := range tests {
+		_, err := ToObject(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("ToObject(%T) wrong error. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	it := New()
+	obj, err := it.Eval(`{
+		"Name": "Ada",
+		"Age": 36,
+		"is_admin": true,
+		"Tags": ["x", "y"],
+		"Home": {"City": "London", "zip_code": "N1"},
+		"Scores": {"go": 1},
+		"Secret": "ignored",
+		"Unknown": 1
+	}`)
+	if err != nil {
+		t.Fatalf("eval error: %s", err)
+	}
+
+	var p person
+	if err := FromObject(obj, &p); err != nil {
+		t.Fatalf("FromObject failed: %s", err)
+	}
+
+	expected := person{
+		Name:   "Ada",
+		Age:    36,
+		Admin:  true,
+		Tags:   []string{"x", "y"},
+		Home:   &address{City: "London", Zip: "N1"},
+		Scores: map[string]int{"go": 1},
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("FromObject wrong.\nwant=%+v\ngot=%+v", expected, p)
+	}
+
+	var native any
+	obj, _ = it.Eval(`[1, "a", true, null, {"k": [2]}, {1: 2}]`)
+	if err := FromObject(obj, &native); err != nil {
+		t.Fatalf("FromObject failed: %s", err)
+	}
+	expectedNative := []any{int64(1), "a", true, nil,
+		map[string]any{"k": []any{int64(2)}}, map[any]any{int64(1): int64(2)}}
+	if !reflect.DeepEqual(native, expectedNative) {
+		t.Errorf("FromObject into any wrong.\nwant=%#v\ngot=%#v", expectedNative, native)
+	}
+
+	var hash *object.Hash
+	obj, _ = it.Eval(`{"a": 1}`)
+	if err := FromObject(obj, &hash); err != nil || hash != obj {
+		t.Errorf("FromObject into *object.Hash failed: %v", err)
+	}
+}
+
+func TestFromObjectErrors(t *testing.T) {
+	it := New()
+
+	tests := []struct {
+		input    string
+		target   any
+		expected string
+	}{
+		{`"a"`, new(int), "cannot convert STRING to int"},
+		{`300`, new(uint8), "300 overflows uint8"},
+		{`-1`, new(uint), "-1 overflows uint"},
+		{`null`, new(string), "cannot convert NULL to string"},
+		{`[1, "a"]`, new([]int), "index 1: cannot convert STRING to int"},
+		{`[1, 2]`, new([3]int), "cannot convert ARRAY of length 2 to [3]int"},
+		{`{"Age": "old"}`, new(person), "field Age: cannot convert STRING to int"},
+		{`fn(x) { x }`, new(int), "cannot convert FUNCTION to int"},
+	}
+
+	for _, tt := range tests {
+		obj, err := it.Eval(tt.input)
+		if err != nil {
+			t.Fatalf("eval error: %s", err)
+		}
+		err = FromObject(obj, tt.target)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("FromObject(%s) wrong error. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+
+	if err := FromObject(object.NULL, 1); err == nil {
+		t.Errorf("FromObject accepted a non-pointer target")
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	for _, engine := range engines {
+		it := New(WithEngine(engine))
+
+		funcs := map[string]any{
+			"add": func(a, b int) int { return a + b },
+			"sum": func(prefix string, nums ...int) string {
+				total := 0
+				for _, n := range nums {
+					total += n
+				}
+				return prefix + strings.Repeat("!", total)
+			},
+			"divide": func(a, b int) (int, error) {
+				if b == 0 {
+					return 0, errors.New("division by zero")
+				}
+				return a / b, nil
+			},
+			"greet": func(p person) string { return "hi " + p.Name },
+			"apply": func(rt object.Runtime, fn object.Object, x int) object.Object {
+				return rt.Call(fn, &object.Integer{Value: int64(x)})
+			},
+			"noop": func() {},
+		}
+		for name, fn := range funcs {
+			if err := it.RegisterFunc(name, fn); err != nil {
+				t.Fatalf("%s: RegisterFunc(%s) failed: %s", engine, name, err)
+			}
+		}
+
+		tests := []struct {
+			input    string
+			expected string
+		}{
+			{`add(1, 2)`, "3"},
+			{`sum("a")`, "a"},
+			{`sum("a", 1, 2)`, "a!!!"},
+			{`divide(7, 2)`, "3"},
+			{`greet({"Name": "Ada"})`, "hi Ada"},
+			{`apply(fn(x) { x * 10 }, 4)`, "40"},
+			{`noop()`, "null"},
+		}
+
+		for _, tt := range tests {
+			result, err := it.Eval(tt.input)
+			if err != nil {
+				t.Errorf("%s: %s failed: %s", engine, tt.input, err)
+				continue
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %s wrong. want=%q, got=%q", engine, tt.input, tt.expected, result.Inspect())
+			}
+		}
+
+		errorTests := []struct {
+			input    string
+			expected string
+		}{
+			{`divide(1, 0)`, "division by zero"},
+			{`add(1)`, "wrong number of arguments. got=1, want=2"},
+			{`sum()`, "wrong number of arguments. got=0, want at least 1"},
+			{`add(1, "b")`, "argument 2 to `add`: cannot convert STRING to int"},
+			{`sum("a", 1, true)`, "argument 3 to `sum`: cannot convert BOOLEAN to int"},
+		}
+
+		for _, tt := range errorTests {
+			_, err := it.Eval(tt.input)
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("%s: %s wrong error. want=%q, got=%v", engine, tt.input, tt.expected, err)
+			}
+		}
+	}
+
+	if err := New().RegisterFunc("bad", func() (int, int) { return 0, 0 }); err == nil {
+		t.Errorf("RegisterFunc accepted a func with two results")
+	}
+	if err := New().RegisterFunc("bad", 1); err == nil {
+		t.Errorf("RegisterFunc accepted a non-func")
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	for _, engine := range engines {
+		it := New(WithEngine(engine))
+
+		err := it.SetValue("config", map[string]any{"name": "svc", "ports": []int{80, 443}})
+		if err != nil {
+			t.Fatalf("%s: SetValue failed: %s", engine, err)
+		}
+
+		result, err := it.Eval(`config["name"] + ":" + str(config["ports"][1])`)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+		if result.Inspect() != "svc:443" {
+			t.Errorf("%s: wrong result %q", engine, result.Inspect())
+		}
+	}
+}
@@ -0,0 +1,406 @@
+// Package interp embeds Monkey in Go programs.
+//
+// An Interpreter holds the global bindings shared by every program it
+// compiles, so a program can be compiled once and run many times, and Go
+// code can read and write its globals and call its functions in between:
+//
+//	it := interp.New(interp.WithEngine(interp.VM))
+//	it.Register("greet", greet)
+//	prog, err := it.Compile(`let double = fn(x) { x * 2 };`)
+//	_, err = prog.Run()
+//	result, err := it.Call("double", &object.Integer{Value: 21})
+package interp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"strings"
+)
+
+// Engine selects how an Interpreter runs programs.
+type Engine int
+
+const (
+	// VM compiles programs to bytecode and runs them on the virtual machine.
+	VM Engine = iota
+	// Evaluator walks the AST of programs.
+	Evaluator
+)
+
+func (e Engine) String() string {
+	switch e {
+	case VM:
+		return "vm"
+	case Evaluator:
+		return "evaluator"
+	default:
+		return fmt.Sprintf("Engine(%d)", int(e))
+	}
+}
+
+// Option configures an Interpreter.
+type Option func(*Interpreter)
+
+// WithEngine selects the engine. The default is VM.
+func WithEngine(engine Engine) Option {
+	return func(it *Interpreter) {
+		it.engine = engine
+	}
+}
+
+// WithHost grants programs access to what host allows. Without it, programs
+// can only print with puts.
+func WithHost(host *object.Host) Option {
+	return func(it *Interpreter) {
+		it.host = host
+	}
+}
+
+// WithStepLimit stops programs and calls after maxSteps steps with an
+// error wrapping object.ErrStepLimitExceeded. The VM counts instructions,
+// the evaluator statements and function calls.
+func WithStepLimit(maxSteps int64) Option {
+	return func(it *Interpreter) {
+		it.budget.MaxSteps = maxSteps
+	}
+}
+
+// WithMaxDepth limits programs and calls to maxDepth nested function
+// calls. Deeper calls fail with an error. The evaluator defaults to
+// evaluator.DefaultMaxDepth. The VM defaults to a depth derived from its
+// stack size, and a full stack can stop calls before they reach maxDepth.
+func WithMaxDepth(maxDepth int) Option {
+	return func(it *Interpreter) {
+		it.maxDepth = maxDepth
+	}
+}
+
+// WithAllocationLimit stops programs and calls once they have allocated
+// more than maxBytes of arrays, strings and hashes in total, with an error
+// wrapping object.ErrAllocationLimitExceeded. Memory that became garbage
+// still counts, so this bounds the allocation work of a run rather than
+// the memory it holds. See object.SizeOf for how sizes are estimated.
+func WithAllocationLimit(maxBytes int64) Option {
+	return func(it *Interpreter) {
+		it.budget.MaxAllocated = maxBytes
+	}
+}
+
+// WithOptimizations compiles programs with compiler.WithOptimizations. It
+// only affects the VM engine.
+func WithOptimizations() Option {
+	return func(it *Interpreter) {
+		it.optimize = true
+	}
+}
+
+// ParseError reports the syntax errors of a program.
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return "parse errors:\n\t" + strings.Join(e.Errors, "\n\t")
+}
+
+// Interpreter runs Monkey programs against a shared set of globals. It is
+// not safe for concurrent use.
+type Interpreter struct {
+	engine   Engine
+	host     *object.Host
+	budget   object.Budget
+	maxDepth int
+	optimize bool
+	// bytes allocated by the last run or call
+	allocated int64
+
+	// state of the VM engine
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+	random      *rand.Rand
+
+	// state of the evaluator engine
+	env *object.Environment
+}
+
+// New returns an Interpreter without any globals.
+func New(opts ...Option) *Interpreter {
+	it := &Interpreter{engine: VM}
+	for _, opt := range opts {
+		opt(it)
+	}
+
+	switch it.engine {
+	case VM:
+		it.symbolTable = compiler.NewSymbolTable()
+		for i, v := range object.Builtins {
+			it.symbolTable.DefineBuiltin(i, v.Name)
+		}
+		it.globals = make([]object.Object, vm.GlobalsSize)
+		it.random = object.NewRandom()
+	case Evaluator:
+		it.env = object.NewEnvironment()
+		it.env.SetHost(it.host)
+		it.env.SetMaxDepth(it.maxDepth)
+	default:
+		panic(fmt.Sprintf("interp: unknown engine %s", it.engine))
+	}
+
+	return it
+}
+
+// Engine returns the engine programs run on.
+func (it *Interpreter) Engine() Engine {
+	return it.engine
+}
+
+// Set binds the global name to value. Programs compiled afterwards can use
+// it like a global defined with let.
+func (it *Interpreter) Set(name string, value object.Object) {
+	if it.engine == Evaluator {
+		it.env.Set(name, value)
+		return
+	}
+
+	symbol, ok := it.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		symbol = it.symbolTable.Define(name)
+	}
+	it.globals[symbol.Index] = value
+}
+
+// Get returns the value of the global name.
+func (it *Interpreter) Get(name string) (object.Object, bool) {
+	if it.engine == Evaluator {
+		return it.env.Get(name)
+	}
+
+	symbol, ok := it.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		return nil, false
+	}
+	value := it.globals[symbol.Index]
+	return value, value != nil
+}
+
+// Register makes fn callable from programs as the global name. A Go
+// function reports failures by returning an *object.Error.
+func (it *Interpreter) Register(name string, fn object.BuiltinFunction) {
+	it.Set(name, &object.Builtin{Fn: fn})
+}
+
+// SetValue binds the global name to v converted with ToObject.
+func (it *Interpreter) SetValue(name string, v any) error {
+	value, err := ToObject(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	it.Set(name, value)
+	return nil
+}
+
+// RegisterFunc makes the Go function fn callable from programs as the
+// global name, converting arguments and results as described at Func.
+func (it *Interpreter) RegisterFunc(name string, fn any) error {
+	builtin, err := Func(name, fn)
+	if err != nil {
+		return err
+	}
+	it.Set(name, builtin)
+	return nil
+}
+
+// Program is a compiled program, ready to be run any number of times.
+type Program struct {
+	it       *Interpreter
+	program  *ast.Program
+	bytecode *compiler.Bytecode
+	warnings []compiler.Warning
+}
+
+// Compile parses input and, for the VM engine, compiles it to bytecode.
+// Globals the program defines are known to programs compiled later.
+func (it *Interpreter) Compile(input string) (*Program, error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	if it.engine == Evaluator {
+		return &Program{it: it, program: program, warnings: compiler.Analyze(program)}, nil
+	}
+
+	// The VM leaves the value of the last expression it popped, so a program
+	// ending in a statement without value gets an explicit null, as in the
+	// evaluator.
+	if !endsWithValue(program) {
+		program.Statements = append(program.Statements, &ast.ExpressionStatement{Expression: &ast.Null{}})
+	}
+
+	var opts []compiler.Option
+	if it.optimize {
+		opts = append(opts, compiler.WithOptimizations())
+	}
+	comp := compiler.NewWithState(it.symbolTable, it.constants, opts...)
+	err := comp.Compile(program)
+	if err != nil {
+		return nil, err
+	}
+
+	bytecode := comp.Bytecode()
+	it.constants = bytecode.Constants
+
+	return &Program{it: it, bytecode: bytecode, warnings: comp.Warnings()}, nil
+}
+
+// Warnings returns the warnings about suspicious code in the program, such
+// as code after a return. They are the same for both engines.
+func (p *Program) Warnings() []compiler.Warning {
+	return p.warnings
+}
+
+// endsWithValue reports whether the last statement of program produces its
+// result: an expression statement or a return.
+func endsWithValue(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+	switch program.Statements[len(program.Statements)-1].(type) {
+	case *ast.ExpressionStatement, *ast.ReturnStatement:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run runs the program and returns the value of its last expression.
+func (p *Program) Run() (object.Object, error) {
+	return p.RunContext(context.Background())
+}
+
+// RunContext is like Run, but stops the program once ctx is done with an
+// error wrapping object.ErrCanceled and ctx.Err().
+func (p *Program) RunContext(ctx context.Context) (object.Object, error) {
+	it := p.it
+
+	if it.engine == Evaluator {
+		return it.evalLimited(ctx, func() object.Object {
+			return evaluator.Eval(p.program, it.env)
+		})
+	}
+
+	machine := it.newVM(p.bytecode.Instructions)
+	err := machine.RunContext(ctx)
+	it.allocated = machine.Allocated()
+	if err != nil {
+		return nil, err
+	}
+
+	return result(machine.LastPoppedStackElement(), nil)
+}
+
+// Eval compiles and runs input once.
+func (it *Interpreter) Eval(input string) (object.Object, error) {
+	return it.EvalContext(context.Background(), input)
+}
+
+// EvalContext compiles input and runs it once with RunContext.
+func (it *Interpreter) EvalContext(ctx context.Context, input string) (object.Object, error) {
+	prog, err := it.Compile(input)
+	if err != nil {
+		return nil, err
+	}
+	return prog.RunContext(ctx)
+}
+
+// Call calls the function bound to the global name with args.
+func (it *Interpreter) Call(name string, args ...object.Object) (object.Object, error) {
+	return it.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is like Call, but stops the call once ctx is done.
+func (it *Interpreter) CallContext(ctx context.Context, name string, args ...object.Object) (object.Object, error) {
+	fn, ok := it.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("identifier not found: %s", name)
+	}
+	return it.CallValueContext(ctx, fn, args...)
+}
+
+// CallValue calls fn, a function value obtained from this Interpreter or a
+// builtin, with args.
+func (it *Interpreter) CallValue(fn object.Object, args ...object.Object) (object.Object, error) {
+	return it.CallValueContext(context.Background(), fn, args...)
+}
+
+// CallValueContext is like CallValue, but stops the call once ctx is done.
+func (it *Interpreter) CallValueContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	if it.engine == Evaluator {
+		return it.evalLimited(ctx, func() object.Object {
+			return evaluator.Apply(fn, args, it.env)
+		})
+	}
+
+	machine := it.newVM(nil)
+	obj, err := machine.CallContext(ctx, fn, args...)
+	it.allocated = machine.Allocated()
+	return result(obj, err)
+}
+
+// Allocated returns the total bytes of arrays, strings and hashes the last
+// run or call allocated, including values that became garbage. It is only
+// counted under limits, e.g. WithAllocationLimit, and is zero otherwise.
+func (it *Interpreter) Allocated() int64 {
+	return it.allocated
+}
+
+func (it *Interpreter) evalLimited(ctx context.Context, eval func() object.Object) (object.Object, error) {
+	limits := object.NewLimits(ctx, it.budget)
+	obj, err := evaluator.WithLimits(it.env, limits, eval)
+	if limits != nil {
+		it.allocated = limits.Allocated()
+	}
+	return result(obj, err)
+}
+
+// newVM returns a VM running instructions against the globals. It gets all
+// constants compiled so far rather than only those of one program, because
+// functions of other programs may be stored in the globals.
+func (it *Interpreter) newVM(instructions []byte) *vm.VM {
+	bytecode := &compiler.Bytecode{Instructions: instructions, Constants: it.constants}
+	opts := []vm.Option{
+		vm.WithHost(it.host), vm.WithRandom(it.random),
+		vm.WithStepLimit(it.budget.MaxSteps), vm.WithAllocationLimit(it.budget.MaxAllocated),
+	}
+	if it.maxDepth > 0 {
+		// the frame of the program itself is not a call
+		opts = append(opts, vm.WithMaxFrames(it.maxDepth+1))
+	}
+	return vm.NewWithGlobalsState(bytecode, it.globals, opts...)
+}
+
+// result turns a runtime error object into a Go error. Programs that end
+// with a statement without value result in null.
+func result(obj object.Object, err error) (object.Object, error) {
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return object.NULL, nil
+	}
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, errors.New(errObj.Message)
+	}
+	return obj, nil
+}
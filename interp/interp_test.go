@@ -0,0 +1,442 @@
+package interp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"monkey/object"
+	"testing"
+)
+
+var engines = []Engine{VM, Evaluator}
+
+func TestCompileOnceRunMany(t *testing.T) {
+	for _, engine := range engines {
+		it := New(WithEngine(engine))
+		it.Set("x", &object.Integer{Value: 0})
+
+		prog, err := it.Compile(`let y = x * 2; y + 1`)
+		if err != nil {
+			t.Fatalf("%s: compile error: %s", engine, err)
+		}
+
+		for i := int64(1); i <= 3; i++ {
+			it.Set("x", &object.Integer{Value: i})
+
+			result, err := prog.Run()
+			if err != nil {
+				t.Fatalf("%s: run error: %s", engine, err)
+			}
+			testInteger(t, engine, result, i*2+1)
+
+			y, ok := it.Get("y")
+			if !ok {
+				t.Fatalf("%s: y not found", engine)
+			}
+			testInteger(t, engine, y, i*2)
+		}
+	}
+}
+
+func TestResultOfStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1; let x = 2;`, "null"},
+		{`let x = 2; x; let y = 3;`, "null"},
+		{``, "null"},
+		{`let x = 2; x`, "2"},
+		{`return 7; 8`, "7"},
+		{`if (true) { return 5; }; let z = 1;`, "5"},
+	}
+
+	for _, engine := range engines {
+		for _, tt := range tests {
+			result, err := New(WithEngine(engine)).Eval(tt.input)
+			if err != nil {
+				t.Fatalf("%s: eval error: %s", engine, err)
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: wrong result for %q. want=%s, got=%s", engine, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let f = fn() {\n  return 1;\n  2\n};\nf()", []string{"line 3, column 3: unreachable code"}},
+		{`let f = fn(a) { if (a) { return 1 }; 2 }; f(true)`, nil},
+	}
+
+	for _, engine := range engines {
+		for _, tt := range tests {
+			prog, err := New(WithEngine(engine)).Compile(tt.input)
+			if err != nil {
+				t.Fatalf("%s: compile error: %s", engine, err)
+			}
+
+			warnings := prog.Warnings()
+			if len(warnings) != len(tt.expected) {
+				t.Fatalf("%s: wrong number of warnings for %q. want=%q, got=%v", engine, tt.input, tt.expected, warnings)
+			}
+			for i, warning := range warnings {
+				if warning.String() != tt.expected[i] {
+					t.Errorf("%s: wrong warning. want=%q, got=%q", engine, tt.expected[i], warning)
+				}
+			}
+		}
+	}
+}
+
+func TestGlobalsAcrossPrograms(t *testing.T) {
+	for _, engine := range engines {
+		it := New(WithEngine(engine))
+
+		_, err := it.Eval(`let add = fn(a, b) { a + b }; let base = 10;`)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+
+		result, err := it.Eval(`add(base, 5)`)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+		testInteger(t, engine, result, 15)
+
+		if _, ok := it.Get("missing"); ok {
+			t.Errorf("%s: Get found an undefined global", engine)
+		}
+		if _, ok := it.Get("len"); ok {
+			t.Errorf("%s: Get found a builtin", engine)
+		}
+	}
+}
+
+func TestRandomPerInterpreter(t *testing.T) {
+	draw := `map(range(5), fn(i) { random(1000) })`
+
+	var first string
+	for _, engine := range engines {
+		seeded := New(WithEngine(engine))
+		other := New(WithEngine(engine))
+
+		_, err := seeded.Eval(`seed(42);`)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+		// seed does not reach other interpreters
+		_, err = other.Eval(`seed(7); random(1000)`)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+		// and stays in effect for later programs of the same interpreter
+		result, err := seeded.Eval(draw)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+
+		if first == "" {
+			first = result.Inspect()
+		} else if result.Inspect() != first {
+			t.Errorf("%s: seeded run not reproducible. want=%s, got=%s", engine, first, result.Inspect())
+		}
+
+		fresh, err := New(WithEngine(engine)).Eval(`seed(42); ` + draw)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+		if fresh.Inspect() != first {
+			t.Errorf("%s: seeded run not reproducible. want=%s, got=%s", engine, first, fresh.Inspect())
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	for _, engine := range engines {
+		it := New(WithEngine(engine))
+
+		_, err := it.Eval(`
+		let makeAdder = fn(a) { fn(b) { a + b } };
+		let addTwo = makeAdder(2);
+		let apply = fn(f, x) { f(x) };
+		`)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+
+		result, err := it.Call("addTwo", &object.Integer{Value: 40})
+		if err != nil {
+			t.Fatalf("%s: call error: %s", engine, err)
+		}
+		testInteger(t, engine, result, 42)
+
+		addTwo, _ := it.Get("addTwo")
+		result, err = it.Call("apply", addTwo, &object.Integer{Value: 1})
+		if err != nil {
+			t.Fatalf("%s: call error: %s", engine, err)
+		}
+		testInteger(t, engine, result, 3)
+
+		_, err = it.Call("addTwo")
+		if err == nil || err.Error() != "wrong number of arguments: want=1, got=0" {
+			t.Errorf("%s: wrong error for bad arity: %v", engine, err)
+		}
+
+		_, err = it.Call("missing")
+		if err == nil || err.Error() != "identifier not found: missing" {
+			t.Errorf("%s: wrong error for missing function: %v", engine, err)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	for _, engine := range engines {
+		it := New(WithEngine(engine))
+
+		var calls int
+		it.Register("twice", func(rt object.Runtime, args ...object.Object) object.Object {
+			calls++
+			if len(args) != 2 {
+				return &object.Error{Message: "twice takes a function and a value"}
+			}
+			once := rt.Call(args[0], args[1])
+			if _, ok := once.(*object.Error); ok {
+				return once
+			}
+			return rt.Call(args[0], once)
+		})
+
+		result, err := it.Eval(`twice(fn(x) { x * 3 }, 2)`)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+		testInteger(t, engine, result, 18)
+
+		_, err = it.Eval(`twice(1)`)
+		if err == nil || err.Error() != "twice takes a function and a value" {
+			t.Errorf("%s: wrong error: %v", engine, err)
+		}
+
+		if calls != 2 {
+			t.Errorf("%s: twice called %d times, want=2", engine, calls)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, engine := range engines {
+		it := New(WithEngine(engine))
+
+		_, err := it.Compile(`let = 1;`)
+		if _, ok := err.(*ParseError); !ok {
+			t.Errorf("%s: expected *ParseError, got=%T (%v)", engine, err, err)
+		}
+
+		_, err = it.Eval(`len(1)`)
+		if err == nil || err.Error() != "argument to `len` not supported, got INTEGER" {
+			t.Errorf("%s: wrong runtime error: %v", engine, err)
+		}
+	}
+}
+
+func TestWithHost(t *testing.T) {
+	for _, engine := range engines {
+		var out bytes.Buffer
+		host := &object.Host{Stdout: &out, Args: []string{"a"}}
+		it := New(WithEngine(engine), WithHost(host))
+
+		_, err := it.Eval(`let show = fn() { puts(args()) };`)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+		_, err = it.Call("show")
+		if err != nil {
+			t.Fatalf("%s: call error: %s", engine, err)
+		}
+
+		if out.String() != "[a]\n" {
+			t.Errorf("%s: puts wrote %q", engine, out.String())
+		}
+	}
+}
+
+func testInteger(t *testing.T, engine Engine, obj object.Object, expected int64) {
+	t.Helper()
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("%s: object is not Integer. got=%T (%+v)", engine, obj, obj)
+		return
+	}
+	if result.Value != expected {
+		t.Errorf("%s: object has wrong value. got=%d, want=%d", engine, result.Value, expected)
+	}
+}
+
+func TestExecutionLimits(t *testing.T) {
+	for _, engine := range engines {
+		it := New(WithEngine(engine), WithStepLimit(1000))
+
+		_, err := it.Eval(`let spin = fn(n) { map(range(n), fn(x) { x }) };`)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+
+		_, err = it.Eval(`spin(10000)`)
+		if !errors.Is(err, object.ErrStepLimitExceeded) {
+			t.Errorf("%s: expected step limit error, got=%v", engine, err)
+		}
+
+		_, err = it.Call("spin", &object.Integer{Value: 10000})
+		if !errors.Is(err, object.ErrStepLimitExceeded) {
+			t.Errorf("%s: expected step limit error from Call, got=%v", engine, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = it.CallContext(ctx, "spin", &object.Integer{Value: 1})
+		if !errors.Is(err, object.ErrCanceled) {
+			t.Errorf("%s: expected cancellation error, got=%v", engine, err)
+		}
+
+		result, err := it.Call("spin", &object.Integer{Value: 3})
+		if err != nil || result.Inspect() != "[0, 1, 2]" {
+			t.Errorf("%s: call after limits failed: %v, %v", engine, result, err)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	depth := `let depth = fn(n) { if (n == 0) { 0 } else { 1 + depth(n - 1) } };`
+
+	for _, engine := range engines {
+		limited := New(WithEngine(engine), WithMaxDepth(50))
+		unlimited := New(WithEngine(engine))
+		for _, it := range []*Interpreter{limited, unlimited} {
+			if _, err := it.Eval(depth); err != nil {
+				t.Fatalf("%s: eval error: %s", engine, err)
+			}
+		}
+
+		result, err := limited.Call("depth", &object.Integer{Value: 40})
+		if err != nil {
+			t.Fatalf("%s: call error: %s", engine, err)
+		}
+		testInteger(t, engine, result, 40)
+
+		_, err = limited.Call("depth", &object.Integer{Value: 60})
+		if err == nil {
+			t.Errorf("%s: expected an error past the maximum depth", engine)
+		}
+
+		// the limit belongs to one interpreter
+		result, err = unlimited.Call("depth", &object.Integer{Value: 60})
+		if err != nil {
+			t.Fatalf("%s: call error: %s", engine, err)
+		}
+		testInteger(t, engine, result, 60)
+	}
+}
+
+func TestAllocated(t *testing.T) {
+	for _, engine := range engines {
+		it := New(WithEngine(engine), WithAllocationLimit(math.MaxInt64))
+
+		_, err := it.Eval(`let s = "ab" + "cd"; [s, s]`)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+		// "abcd" and an array of two elements
+		if it.Allocated() != 20+56 {
+			t.Errorf("%s: wrong allocation. want=%d, got=%d", engine, 20+56, it.Allocated())
+		}
+
+		it = New(WithEngine(engine), WithAllocationLimit(1000))
+		_, err = it.Eval(`let f = fn(n) { split(repeat("x,", n), ",") };`)
+		if err != nil {
+			t.Fatalf("%s: eval error: %s", engine, err)
+		}
+		_, err = it.Call("f", &object.Integer{Value: 1000})
+		if !errors.Is(err, object.ErrAllocationLimitExceeded) {
+			t.Errorf("%s: expected allocation limit error, got=%v", engine, err)
+		}
+		if it.Allocated() > 1000 {
+			t.Errorf("%s: allocation exceeds the limit: %d", engine, it.Allocated())
+		}
+
+		// builtins returning existing values allocate nothing
+		it = New(WithEngine(engine), WithAllocationLimit(1<<20))
+		_, err = it.Eval(`
+		let big = [range(0, 1000)];
+		let one = [1];
+		let loop = fn(n) {
+			if (n == 0) { return len(big); }
+			let a = first(big);
+			let b = last(big);
+			let c = to_array(a);
+			let d = reduce(one, big, fn(acc, x) { acc });
+			loop(n - 1)
+		};
+		loop(2000)
+		`)
+		if err != nil {
+			t.Errorf("%s: builtins returning existing values were counted: %s", engine, err)
+		}
+		if it.Allocated() > 20000 {
+			t.Errorf("%s: wrong allocation. got=%d", engine, it.Allocated())
+		}
+	}
+}
+
+func TestOptimizations(t *testing.T) {
+	it := New(WithOptimizations())
+
+	_, err := it.Eval(`let scale = fn(x) { x * (60 * 60) }; let hour = 1;`)
+	if err != nil {
+		t.Fatalf("eval error: %s", err)
+	}
+	result, err := it.Eval(`hour; scale(hour + 1)`)
+	if err != nil {
+		t.Fatalf("eval error: %s", err)
+	}
+	testInteger(t, VM, result, 7200)
+
+	if len(it.constants) != 3 {
+		t.Errorf("wrong number of constants. want=3, got=%d", len(it.constants))
+	}
+}
+
+// An if whose other branch returns still yields its value through a phi,
+// so folding with its neighbours must not move it out of its block.
+func TestOptimizedIfWithReturningBranch(t *testing.T) {
+	inputs := []string{
+		`let f = fn(x) { [1, (if (x) { return 0; } else { 5 }) + 2] }; f(false)`,
+		`let f = fn(x) { [1, 2 * (if (x) { 5 } else { return 0; })] }; f(true)`,
+		`[1, (if (false) { return 0; } else { 5 }) + 2]`,
+		`[1, 2 * (if (true) { 5 } else { return 0; }), 3]`,
+		`let x = 1; [x, (if (x > 2) { return 0 } else { "a" }) + "b"]`,
+	}
+
+	for _, input := range inputs {
+		expected, err := New(WithEngine(Evaluator)).Eval(input)
+		if err != nil {
+			t.Fatalf("%s: evaluator: eval error: %s", input, err)
+		}
+
+		for _, opts := range [][]Option{nil, {WithOptimizations()}} {
+			result, err := New(opts...).Eval(input)
+			if err != nil {
+				t.Fatalf("%s: eval error: %s", input, err)
+			}
+			if result.Inspect() != expected.Inspect() {
+				t.Errorf("%s: VM with %d options differs from evaluator. want=%s, got=%s",
+					input, len(opts), expected.Inspect(), result.Inspect())
+			}
+		}
+	}
+}
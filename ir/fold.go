@@ -0,0 +1,146 @@
+package ir
+
+import "monkey/object"
+
+/*
+FoldConstants - 피연산자가 모두 상수인 연산을 VM과 똑같은 규칙으로 계산해 상수로 바꾼다. 클로저의 함수도 함께 접는다.
+값은 계산된 순서대로 있으므로 앞에서부터 접으면 1 + 2 * 3처럼 중첩된 연산도 한 번에 상수가 된다.
+피연산자였던 상수는 다른 곳에서 쓰이지 않으므로 블록에서 지운다.
+
+피연산자가 다른 블록에 있으면 접지 않는다. 그 값은 그 블록에서 스택에 쌓이므로 지울 수 없다.
+
+VM에서 에러가 나는 연산(0으로 나누기, 타입이 맞지 않는 연산)은 실행할 때 에러가 나도록 접지 않는다.
+접어서 만든 문자열은 VM의 할당 제한에 잡히지 않는다.
+*/
+func FoldConstants(f *Function) {
+	for _, b := range f.Blocks {
+		for i := 0; i < len(b.Values); i++ {
+			v := b.Values[i]
+			if v.Op == OpClosure {
+				FoldConstants(v.Func)
+				continue
+			}
+
+			result, ok := fold(v)
+			if !ok || !definedIn(b, v.Args) {
+				continue
+			}
+			for _, arg := range v.Args {
+				if j := b.Remove(arg); j != -1 && j < i {
+					i--
+				}
+			}
+
+			v.Op, v.Type, v.Const, v.Args = OpConst, TypeOf(result), result, nil
+		}
+	}
+}
+
+/*
+definedIn - 값이 모두 블록 b에 있는지
+*/
+func definedIn(b *Block, values []*Value) bool {
+	for _, v := range values {
+		found := false
+		for _, value := range b.Values {
+			if value == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func fold(v *Value) (object.Object, bool) {
+	if len(v.Args) == 0 {
+		return nil, false
+	}
+
+	operands := make([]object.Object, len(v.Args))
+	for i, arg := range v.Args {
+		if arg.Op != OpConst {
+			return nil, false
+		}
+		operands[i] = arg.Const
+	}
+
+	switch v.Op {
+	case OpMinus, OpBang:
+		return foldUnary(v.Op, operands[0])
+	case OpAdd, OpSub, OpMul, OpDiv, OpEqual, OpNotEqual, OpGreaterThan:
+		return foldBinary(v.Op, operands[0], operands[1])
+	default:
+		return nil, false
+	}
+}
+
+func foldUnary(op Op, operand object.Object) (object.Object, bool) {
+	switch op {
+	case OpBang:
+		switch operand {
+		case object.TRUE:
+			return object.FALSE, true
+		case object.FALSE, object.NULL:
+			return object.TRUE, true
+		default:
+			return object.FALSE, true
+		}
+	case OpMinus:
+		if integer, ok := operand.(*object.Integer); ok {
+			return &object.Integer{Value: -integer.Value}, true
+		}
+	}
+	return nil, false
+}
+
+func foldBinary(op Op, left, right object.Object) (object.Object, bool) {
+	leftInt, leftIsInt := left.(*object.Integer)
+	rightInt, rightIsInt := right.(*object.Integer)
+
+	if leftIsInt && rightIsInt {
+		l, r := leftInt.Value, rightInt.Value
+		switch op {
+		case OpAdd:
+			return &object.Integer{Value: l + r}, true
+		case OpSub:
+			return &object.Integer{Value: l - r}, true
+		case OpMul:
+			return &object.Integer{Value: l * r}, true
+		case OpDiv:
+			if r == 0 {
+				return nil, false
+			}
+			return &object.Integer{Value: l / r}, true
+		case OpGreaterThan:
+			return Bool(l > r), true
+		}
+	}
+
+	switch op {
+	case OpEqual:
+		return Bool(object.Equals(left, right)), true
+	case OpNotEqual:
+		return Bool(!object.Equals(left, right)), true
+	case OpAdd:
+		leftStr, leftIsStr := left.(*object.String)
+		rightStr, rightIsStr := right.(*object.String)
+		if leftIsStr && rightIsStr {
+			return &object.String{Value: leftStr.Value + rightStr.Value}, true
+		}
+	}
+	return nil, false
+}
+
+/*
+Bool - 참, 거짓 상수
+*/
+func Bool(b bool) *object.Boolean {
+	if b {
+		return object.TRUE
+	}
+	return object.FALSE
+}
@@ -0,0 +1,407 @@
+/*
+Package ir - AST와 바이트코드 사이의 중간 표현.
+
+컴파일러는 AST를 바로 명령어로 바꾸지 않고 먼저 함수마다 기본 블록(basic block)의 그래프를 만든다.
+블록은 값(Value)의 목록과 블록을 끝내는 종결자(Term)로 이루어진다.
+
+값은 SSA 형식의 3-주소 코드다. 값마다 한 번만 정의되고 %번호로 가리키며, 컴파일러가 아는 정적 타입이 붙는다.
+let 바인딩은 VM처럼 슬롯에 남겨두고 load, store로 읽고 쓴다. if와 ??처럼 갈래가 합쳐지는 곳의 값은 phi로 고른다.
+
+VM이 스택 머신이므로 값은 다음 규칙을 지키며 만든다. 그래서 명령어로 바꾸는 일(lowering)은 값마다 명령어 하나를 내보내는 것으로 끝난다.
+
+  - 모든 값은 정확히 한 번 쓰인다.
+  - 피연산자는 계산된 순서대로 스택에 쌓여있다. 즉, 값의 피연산자는 스택 최상단의 값들이다.
+  - phi의 피연산자는 각 선행 블록이 끝날 때 스택 최상단에 있는 값이다. phi 자체는 명령어가 되지 않는다.
+*/
+package ir
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/object"
+	"strings"
+)
+
+/*
+Type - 컴파일할 때 알 수 있는 값의 타입. 알 수 없으면 TypeAny다.
+*/
+type Type int
+
+const (
+	TypeAny Type = iota
+	TypeInteger
+	TypeBoolean
+	TypeString
+	TypeNull
+	TypeArray
+	TypeHash
+	TypeFunction
+)
+
+var typeNames = map[Type]string{
+	TypeAny:      "any",
+	TypeInteger:  "int",
+	TypeBoolean:  "bool",
+	TypeString:   "string",
+	TypeNull:     "null",
+	TypeArray:    "array",
+	TypeHash:     "hash",
+	TypeFunction: "fn",
+}
+
+func (t Type) String() string {
+	return typeNames[t]
+}
+
+/*
+TypeOf - 상수의 타입
+*/
+func TypeOf(obj object.Object) Type {
+	switch obj.(type) {
+	case *object.Integer:
+		return TypeInteger
+	case *object.Boolean:
+		return TypeBoolean
+	case *object.String:
+		return TypeString
+	case *object.Null:
+		return TypeNull
+	default:
+		return TypeAny
+	}
+}
+
+type Op int
+
+const (
+	OpConst Op = iota // Const를 만든다.
+	OpLoad            // Var를 읽는다.
+	OpStore           // Args[0]을 Var에 저장한다. 결과 값은 없다.
+	OpPop             // Args[0]을 버린다. 결과 값은 없다.
+	OpAdd             // 이항 연산의 피연산자는 Args[0], Args[1]
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpMinus // 단항 연산의 피연산자는 Args[0]
+	OpBang
+	OpInterpolate // Args를 차례로 이어 붙인 문자열
+	OpArray       // Args를 요소로 하는 배열
+	OpHash        // Args는 키, 값, 키, 값... 순서
+	OpIndex       // Args[0][Args[1]]
+	OpSafeIndex
+	OpSlice // Args[0][Args[1]:Args[2]]. 생략된 범위는 null
+	OpSafeSlice
+	OpCall     // Args[0]을 Args[1:]로 호출한다.
+	OpTailCall // 꼬리 위치의 OpCall
+	OpClosure  // Func를 Args를 자유 변수로 묶은 클로저로 만든다.
+	OpPhi      // Preds[i]에서 왔다면 Args[i]
+)
+
+var opNames = map[Op]string{
+	OpConst:       "const",
+	OpLoad:        "load",
+	OpStore:       "store",
+	OpPop:         "pop",
+	OpAdd:         "add",
+	OpSub:         "sub",
+	OpMul:         "mul",
+	OpDiv:         "div",
+	OpEqual:       "eq",
+	OpNotEqual:    "ne",
+	OpGreaterThan: "gt",
+	OpMinus:       "neg",
+	OpBang:        "not",
+	OpInterpolate: "interpolate",
+	OpArray:       "array",
+	OpHash:        "hash",
+	OpIndex:       "index",
+	OpSafeIndex:   "safeindex",
+	OpSlice:       "slice",
+	OpSafeSlice:   "safeslice",
+	OpCall:        "call",
+	OpTailCall:    "tailcall",
+	OpClosure:     "closure",
+	OpPhi:         "phi",
+}
+
+func (op Op) String() string {
+	if name, ok := opNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+/*
+HasResult - store와 pop을 뺀 모든 연산은 값을 만든다.
+*/
+func (op Op) HasResult() bool {
+	return op != OpStore && op != OpPop
+}
+
+/*
+Scope - 바인딩이 저장된 곳. 컴파일러의 심볼 스코프와 같다.
+*/
+type Scope int
+
+const (
+	Global Scope = iota
+	Local
+	Builtin
+	Free
+	Self // 실행 중인 클로저 자신
+)
+
+var scopeNames = map[Scope]string{
+	Global:  "global",
+	Local:   "local",
+	Builtin: "builtin",
+	Free:    "free",
+	Self:    "self",
+}
+
+func (s Scope) String() string {
+	return scopeNames[s]
+}
+
+/*
+Var - load, store가 가리키는 바인딩. Name은 덤프를 읽기 쉽게 하기 위한 것이다.
+*/
+type Var struct {
+	Scope Scope
+	Index int
+	Name  string
+}
+
+func (v Var) String() string {
+	if v.Scope == Self {
+		return fmt.Sprintf("self (%s)", v.Name)
+	}
+	return fmt.Sprintf("%s %d (%s)", v.Scope, v.Index, v.Name)
+}
+
+/*
+Value - 연산 하나와 그 결과 값. 결과가 없는 연산(store, pop)의 ID는 0이다.
+*/
+type Value struct {
+	ID    int
+	Op    Op
+	Type  Type
+	Args  []*Value
+	Const object.Object // OpConst
+	Var   Var           // OpLoad, OpStore
+	Func  *Function     // OpClosure
+	Preds []*Block      // OpPhi
+}
+
+/*
+TermKind - 블록을 끝내는 방법
+*/
+type TermKind int
+
+const (
+	// Exit - 종결자가 아직 없다. main 함수의 마지막 블록만 이대로 끝나며, 그러면 프로그램이 끝난다.
+	Exit TermKind = iota
+	Jump          // Targets[0]으로 간다.
+	// Branch - Value가 참으로 평가되면 Targets[0], 아니면 Targets[1]로 간다.
+	Branch
+	// BranchNotNull - Value가 null이 아니면 스택에 남긴 채로 Targets[0]으로, null이면 버리고 Targets[1]로 간다.
+	BranchNotNull
+	Return     // Value를 반환한다.
+	ReturnNull // 반환할 값 없이 반환한다.
+)
+
+type Term struct {
+	Kind    TermKind
+	Value   *Value
+	Targets []*Block
+}
+
+type Block struct {
+	ID     int
+	Values []*Value
+	Term   Term
+}
+
+/*
+Terminated - 블록이 종결자로 끝났는지. 끝난 블록에는 값을 더 추가할 수 없다.
+*/
+func (b *Block) Terminated() bool {
+	return b.Term.Kind != Exit
+}
+
+/*
+Remove - 블록에서 값을 지우고 그 값이 있던 위치를 돌려준다. 블록에 없는 값이면 -1
+최적화 패스가 값을 합친 뒤 피연산자를 지울 때 쓴다.
+*/
+func (b *Block) Remove(v *Value) int {
+	for i, value := range b.Values {
+		if value == v {
+			b.Values = append(b.Values[:i], b.Values[i+1:]...)
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+Function - 함수 본문 하나. Blocks의 순서가 그대로 명령어의 배치 순서이며, Blocks[0]에서 실행을 시작한다.
+main 프로그램도 인자가 없는 Function으로 나타낸다.
+*/
+type Function struct {
+	Name          string
+	NumParameters int
+	NumLocals     int
+	Blocks        []*Block
+
+	nextValueID int
+}
+
+func NewFunction(name string, numParameters int) *Function {
+	return &Function{Name: name, NumParameters: numParameters}
+}
+
+/*
+NewBlock - 새 블록을 배치 순서의 맨 뒤에 추가한다.
+*/
+func (f *Function) NewBlock() *Block {
+	block := &Block{ID: len(f.Blocks)}
+	f.Blocks = append(f.Blocks, block)
+	return block
+}
+
+/*
+NewValue - 함수 안에서 유일한 ID를 가진 값을 만든다. 블록에 추가하지는 않는다.
+*/
+func (f *Function) NewValue(op Op, typ Type, args ...*Value) *Value {
+	value := &Value{Op: op, Type: typ, Args: args}
+	if op.HasResult() {
+		f.nextValueID++
+		value.ID = f.nextValueID
+	}
+	return value
+}
+
+/*
+String - 디버깅용 텍스트 덤프. 함수 안에서 만든 클로저의 함수는 바깥 함수 뒤에 이어서 출력하며 번호(#1)로 가리킨다.
+
+	function #0 main
+	b0:
+	  %1:fn = closure #1
+	  store global 0 (f), %1
+	  exit
+	function #1 f (params=1, locals=1)
+	b0:
+	  %1:any = load local 0 (x)
+	  return %1
+*/
+func (f *Function) String() string {
+	var out bytes.Buffer
+	p := printer{out: &out, ids: map[*Function]int{}}
+	p.function(f)
+	return out.String()
+}
+
+type printer struct {
+	out     *bytes.Buffer
+	ids     map[*Function]int
+	pending []*Function
+}
+
+func (p *printer) id(f *Function) int {
+	if id, ok := p.ids[f]; ok {
+		return id
+	}
+	id := len(p.ids)
+	p.ids[f] = id
+	p.pending = append(p.pending, f)
+	return id
+}
+
+func (p *printer) function(root *Function) {
+	p.id(root)
+
+	for len(p.pending) > 0 {
+		f := p.pending[0]
+		p.pending = p.pending[1:]
+
+		name := f.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		fmt.Fprintf(p.out, "function #%d %s", p.ids[f], name)
+		if f != root {
+			fmt.Fprintf(p.out, " (params=%d, locals=%d)", f.NumParameters, f.NumLocals)
+		}
+		p.out.WriteString("\n")
+
+		for _, b := range f.Blocks {
+			fmt.Fprintf(p.out, "b%d:\n", b.ID)
+			for _, v := range b.Values {
+				p.out.WriteString("  " + p.value(v) + "\n")
+			}
+			p.out.WriteString("  " + p.term(b.Term) + "\n")
+		}
+	}
+}
+
+func (p *printer) value(v *Value) string {
+	operands := []string{}
+	switch v.Op {
+	case OpConst:
+		operands = append(operands, inspectConst(v.Const))
+	case OpLoad, OpStore:
+		operands = append(operands, v.Var.String())
+	case OpClosure:
+		operands = append(operands, fmt.Sprintf("#%d", p.id(v.Func)))
+	}
+
+	for i, arg := range v.Args {
+		if v.Op == OpPhi {
+			operands = append(operands, fmt.Sprintf("[b%d: %s]", v.Preds[i].ID, ref(arg)))
+		} else {
+			operands = append(operands, ref(arg))
+		}
+	}
+
+	instruction := v.Op.String()
+	if len(operands) > 0 {
+		instruction += " " + strings.Join(operands, ", ")
+	}
+	if !v.Op.HasResult() {
+		return instruction
+	}
+	return fmt.Sprintf("%s:%s = %s", ref(v), v.Type, instruction)
+}
+
+func (p *printer) term(t Term) string {
+	switch t.Kind {
+	case Exit:
+		return "exit"
+	case Jump:
+		return fmt.Sprintf("jump b%d", t.Targets[0].ID)
+	case Branch:
+		return fmt.Sprintf("branch %s, b%d, b%d", ref(t.Value), t.Targets[0].ID, t.Targets[1].ID)
+	case BranchNotNull:
+		return fmt.Sprintf("branchnotnull %s, b%d, b%d", ref(t.Value), t.Targets[0].ID, t.Targets[1].ID)
+	case Return:
+		return "return " + ref(t.Value)
+	case ReturnNull:
+		return "return"
+	default:
+		return fmt.Sprintf("Term(%d)", int(t.Kind))
+	}
+}
+
+func ref(v *Value) string {
+	return fmt.Sprintf("%%%d", v.ID)
+}
+
+func inspectConst(obj object.Object) string {
+	if str, ok := obj.(*object.String); ok {
+		return fmt.Sprintf("%q", str.Value)
+	}
+	return obj.Inspect()
+}
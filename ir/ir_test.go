@@ -0,0 +1,123 @@
+package ir
+
+import (
+	"monkey/object"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	inner := NewFunction("double", 1)
+	inner.NumLocals = 1
+	b := inner.NewBlock()
+	x := inner.NewValue(OpLoad, TypeAny)
+	x.Var = Var{Scope: Local, Index: 0, Name: "x"}
+	two := constant(inner, &object.Integer{Value: 2})
+	mul := inner.NewValue(OpMul, TypeAny, x, two)
+	b.Values = append(b.Values, x, two, mul)
+	b.Term = Term{Kind: Return, Value: mul}
+
+	main := NewFunction("main", 0)
+	b = main.NewBlock()
+	closure := main.NewValue(OpClosure, TypeFunction)
+	closure.Func = inner
+	store := main.NewValue(OpStore, TypeAny, closure)
+	store.Var = Var{Scope: Global, Index: 0, Name: "double"}
+	str := constant(main, &object.String{Value: "a"})
+	pop := main.NewValue(OpPop, TypeAny, str)
+	b.Values = append(b.Values, closure, store, str, pop)
+
+	expected := `function #0 main
+b0:
+  %1:fn = closure #1
+  store global 0 (double), %1
+  %2:string = const "a"
+  pop %2
+  exit
+function #1 double (params=1, locals=1)
+b0:
+  %1:any = load local 0 (x)
+  %2:int = const 2
+  %3:any = mul %1, %2
+  return %3
+`
+	if main.String() != expected {
+		t.Errorf("wrong dump.\nwant=\n%s\ngot=\n%s", expected, main.String())
+	}
+}
+
+func TestFoldConstants(t *testing.T) {
+	tests := []struct {
+		op       Op
+		operands []object.Object
+		expected object.Object
+	}{
+		{OpAdd, []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}, &object.Integer{Value: 3}},
+		{OpGreaterThan, []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}, object.FALSE},
+		{OpAdd, []object.Object{&object.String{Value: "a"}, &object.String{Value: "b"}}, &object.String{Value: "ab"}},
+		{OpEqual, []object.Object{object.NULL, object.NULL}, object.TRUE},
+		{OpBang, []object.Object{&object.Integer{Value: 0}}, object.FALSE},
+		{OpMinus, []object.Object{&object.Integer{Value: 5}}, &object.Integer{Value: -5}},
+		// 실행할 때 에러가 나는 연산은 접지 않는다.
+		{OpDiv, []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 0}}, nil},
+		{OpAdd, []object.Object{&object.Integer{Value: 1}, &object.String{Value: "a"}}, nil},
+	}
+
+	for _, tt := range tests {
+		f := NewFunction("main", 0)
+		b := f.NewBlock()
+		args := []*Value{}
+		for _, operand := range tt.operands {
+			arg := constant(f, operand)
+			args = append(args, arg)
+			b.Values = append(b.Values, arg)
+		}
+		v := f.NewValue(tt.op, TypeAny, args...)
+		b.Values = append(b.Values, v, f.NewValue(OpPop, TypeAny, v))
+
+		FoldConstants(f)
+
+		if tt.expected == nil {
+			if v.Op != tt.op || len(b.Values) != len(tt.operands)+2 {
+				t.Errorf("%s %v should not be folded. got=%s", tt.op, tt.operands, f)
+			}
+			continue
+		}
+
+		if v.Op != OpConst || !object.Equals(v.Const, tt.expected) {
+			t.Errorf("%s %v wrong. got=%s", tt.op, tt.operands, f)
+		}
+		if v.Type != TypeOf(tt.expected) {
+			t.Errorf("%s %v type wrong. got=%s, want=%s", tt.op, tt.operands, v.Type, TypeOf(tt.expected))
+		}
+		if len(b.Values) != 2 {
+			t.Errorf("folded operands not removed. got=%s", f)
+		}
+	}
+}
+
+/*
+피연산자가 다른 블록에서 스택에 쌓인 연산은 접지 않는다.
+*/
+func TestFoldConstantsAcrossBlocks(t *testing.T) {
+	f := NewFunction("main", 0)
+	b0 := f.NewBlock()
+	one := constant(f, &object.Integer{Value: 1})
+	b0.Values = append(b0.Values, one)
+	b1 := f.NewBlock()
+	b0.Term = Term{Kind: Jump, Targets: []*Block{b1}}
+	two := constant(f, &object.Integer{Value: 2})
+	add := f.NewValue(OpAdd, TypeAny, one, two)
+	b1.Values = append(b1.Values, two, add, f.NewValue(OpPop, TypeAny, add))
+
+	FoldConstants(f)
+
+	if add.Op != OpAdd || len(b0.Values) != 1 || len(b1.Values) != 3 {
+		t.Errorf("operands in another block should not be folded. got=%s", f)
+	}
+}
+
+func constant(f *Function, obj object.Object) *Value {
+	v := f.NewValue(OpConst, TypeOf(obj))
+	v.Const = obj
+	return v
+}
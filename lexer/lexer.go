@@ -1,16 +1,29 @@
 package lexer
 
-import "monkey/token"
+import (
+	"fmt"
+	"monkey/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 type Lexer struct {
 	input        string
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	ch           rune // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char, counted in runes
+
+	// open ${ interpolations, each with the number of unclosed braces
+	// inside its expression
+	interpolations []int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
@@ -20,6 +33,8 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.skipWhitespace()
 
+	line, column := l.line, l.column
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -43,6 +58,17 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			l.readChar()
+			tok = token.Token{Type: token.COALESCE, Literal: "??"}
+		case '[':
+			l.readChar()
+			tok = token.Token{Type: token.OPT_LBRACKET, Literal: "?["}
+		default:
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '/':
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
@@ -58,16 +84,27 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1] += 1
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		if n := len(l.interpolations); n > 0 && l.interpolations[n-1] == 0 {
+			tok = l.readString(true)
+		} else {
+			if n > 0 {
+				l.interpolations[n-1] -= 1
+			}
+			tok = newToken(token.RBRACE, l.ch)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok = l.readString(false)
+	case '`':
+		tok = l.readRawString()
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
@@ -79,10 +116,12 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Line, tok.Column = line, column
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			tok.Line, tok.Column = line, column
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -90,6 +129,7 @@ func (l *Lexer) NextToken() token.Token {
 	}
 
 	l.readChar()
+	tok.Line, tok.Column = line, column
 	return tok
 }
 
@@ -100,58 +140,180 @@ func (l *Lexer) skipWhitespace() {
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
+	width := 0
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		return ch
 	}
 }
 
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
+// readNumber reads everything that may belong to a numeric literal,
+// including base prefixes, underscores and stray letters. Whether the
+// literal is well formed is left to the parser, which can report a more
+// precise error than an ILLEGAL token.
 func (l *Lexer) readNumber() string {
 	position := l.position
-	for isDigit(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString() string {
+// readString reads a double-quoted string, decoding escape sequences. If
+// the string contains ${ the part before it is returned as an INTERP_HEAD
+// token and lexing continues with the embedded expression; the closing }
+// resumes the string (resumed is true) and yields INTERP_MIDDLE or
+// INTERP_TAIL. On return l.ch is the last character of the token, or 0 if
+// the string is unterminated.
+func (l *Lexer) readString(resumed bool) token.Token {
+	var out strings.Builder
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case '"':
+			if !resumed {
+				return token.Token{Type: token.STRING, Literal: out.String()}
+			}
+			l.interpolations = l.interpolations[:len(l.interpolations)-1]
+			return token.Token{Type: token.INTERP_TAIL, Literal: out.String()}
+		case 0:
+			return token.Token{Type: token.ILLEGAL, Literal: "unterminated string"}
+		case '$':
+			if l.peekChar() != '{' {
+				out.WriteRune(l.ch)
+				continue
+			}
+			l.readChar()
+
+			if !resumed {
+				l.interpolations = append(l.interpolations, 0)
+				return token.Token{Type: token.INTERP_HEAD, Literal: out.String()}
+			}
+			return token.Token{Type: token.INTERP_MIDDLE, Literal: out.String()}
+		case '\\':
+			if err := l.readEscape(&out); err != nil {
+				l.skipString()
+				return token.Token{Type: token.ILLEGAL, Literal: err.Error()}
+			}
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+// readRawString reads a backquoted string verbatim. Raw strings may span
+// several lines and do not interpret escape sequences.
+func (l *Lexer) readRawString() token.Token {
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		if l.ch == '`' {
+			return token.Token{Type: token.STRING, Literal: l.input[position:l.position]}
+		}
+		if l.ch == 0 {
+			return token.Token{Type: token.ILLEGAL, Literal: "unterminated raw string"}
+		}
+	}
+}
+
+// readEscape decodes the escape sequence starting at the backslash under
+// l.ch and leaves l.ch on its last character.
+func (l *Lexer) readEscape(out *strings.Builder) error {
+	l.readChar()
+
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '\\':
+		out.WriteByte('\\')
+	case '"':
+		out.WriteByte('"')
+	case '$':
+		out.WriteByte('$')
+	case 'u':
+		if l.peekChar() != '{' {
+			return fmt.Errorf("invalid unicode escape: expected '{' after \\u")
+		}
+		l.readChar()
+
+		position := l.position + 1
+		for l.peekChar() != '}' && l.peekChar() != '"' && l.peekChar() != 0 {
+			l.readChar()
+		}
+		digits := l.input[position:l.readPosition]
+		if l.peekChar() != '}' {
+			return fmt.Errorf("invalid unicode escape: missing '}' after \\u{%s", digits)
+		}
+		l.readChar()
+
+		code, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || len(digits) > 6 || code > 0x10FFFF || (code >= 0xD800 && code <= 0xDFFF) {
+			return fmt.Errorf("invalid unicode escape: \\u{%s}", digits)
+		}
+		out.WriteRune(rune(code))
+	case 0:
+		return fmt.Errorf("unterminated string")
+	default:
+		return fmt.Errorf("invalid escape sequence: \\%c", l.ch)
+	}
+
+	return nil
+}
+
+// skipString advances to the closing quote of a string that could not be
+// decoded, so the rest of it is not lexed as code.
+func (l *Lexer) skipString() {
+	for l.ch != '"' && l.ch != 0 {
+		if l.ch == '\\' {
+			l.readChar()
+		}
+		if l.ch != 0 {
+			l.readChar()
 		}
 	}
-	return l.input[position:l.position]
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
@@ -141,3 +141,257 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"hello\nworld"`, token.STRING, "hello\nworld"},
+		{`"tab\there"`, token.STRING, "tab\there"},
+		{`"back\\slash"`, token.STRING, `back\slash`},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"\u{48}\u{49}"`, token.STRING, "HI"},
+		{`"\u{1F600}"`, token.STRING, "\U0001F600"},
+		{"`raw\\n \"string\"`", token.STRING, `raw\n "string"`},
+		{"`multi\nline`", token.STRING, "multi\nline"},
+		{`"unterminated`, token.ILLEGAL, "unterminated string"},
+		{"`unterminated", token.ILLEGAL, "unterminated raw string"},
+		{`"bad \q escape"`, token.ILLEGAL, `invalid escape sequence: \q`},
+		{`"\u{110000}"`, token.ILLEGAL, `invalid unicode escape: \u{110000}`},
+		{`"\u41"`, token.ILLEGAL, `invalid unicode escape: expected '{' after \u`},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Fatalf("tests[%d] - expected EOF after string, got=%q (%q)",
+				i, next.Type, next.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  "abc"
+	y`
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.STRING, 2, 3},
+		{token.IDENT, 3, 2},
+		{token.EOF, 3, 3},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestUnicodeInput(t *testing.T) {
+	input := `let 이름 = "한글 문자열";
+let café_2 = "naïve";
+이름 + "😀";
+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "이름", 5},
+		{token.ASSIGN, "=", 8},
+		{token.STRING, "한글 문자열", 10},
+		{token.SEMICOLON, ";", 18},
+		{token.LET, "let", 1},
+		{token.IDENT, "café_2", 5},
+		{token.ASSIGN, "=", 12},
+		{token.STRING, "naïve", 14},
+		{token.SEMICOLON, ";", 21},
+		{token.IDENT, "이름", 1},
+		{token.PLUS, "+", 4},
+		{token.STRING, "😀", 6},
+		{token.SEMICOLON, ";", 9},
+		{token.EOF, "", 1},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - column wrong. expected=%d, got=%d",
+				i, tt.expectedColumn, tok.Column)
+		}
+	}
+}
+
+func TestIdentifiersAndNumbers(t *testing.T) {
+	input := `let x1 = 0xff + 0o17 - 0b1010 * 1_000_000;
+foo_2bar(12abc)`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x1"},
+		{token.ASSIGN, "="},
+		{token.INT, "0xff"},
+		{token.PLUS, "+"},
+		{token.INT, "0o17"},
+		{token.MINUS, "-"},
+		{token.INT, "0b1010"},
+		{token.ASTERISK, "*"},
+		{token.INT, "1_000_000"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "foo_2bar"},
+		{token.LPAREN, "("},
+		{token.INT, "12abc"},
+		{token.RPAREN, ")"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestInterpolatedString(t *testing.T) {
+	input := `"total: ${a + b}!" "${x}${ {"k": "${y}"}["k"] }" "cost: $5 \${no}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INTERP_HEAD, "total: "},
+		{token.IDENT, "a"},
+		{token.PLUS, "+"},
+		{token.IDENT, "b"},
+		{token.INTERP_TAIL, "!"},
+		{token.INTERP_HEAD, ""},
+		{token.IDENT, "x"},
+		{token.INTERP_MIDDLE, ""},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.INTERP_HEAD, ""},
+		{token.IDENT, "y"},
+		{token.INTERP_TAIL, ""},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.INTERP_TAIL, ""},
+		{token.STRING, "cost: $5 ${no}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNullAndOptionalOperators(t *testing.T) {
+	input := `null ?? h?["a"] ? x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.NULL, "null"},
+		{token.COALESCE, "??"},
+		{token.IDENT, "h"},
+		{token.OPT_LBRACKET, "?["},
+		{token.STRING, "a"},
+		{token.RBRACKET, "]"},
+		{token.ILLEGAL, "?"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
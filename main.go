@@ -1,13 +1,53 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"monkey/compiler"
+	"monkey/object"
 	"monkey/repl"
 	"os"
 	"os/user"
+	"strings"
 )
 
+// pathList collects the paths of a flag that may be repeated.
+type pathList []string
+
+func (l *pathList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *pathList) Set(path string) error {
+	*l = append(*l, path)
+	return nil
+}
+
 func main() {
+	var readPaths, writePaths pathList
+	flag.Var(&readPaths, "allow-read", "let scripts read files under `path` (repeatable)")
+	flag.Var(&writePaths, "allow-write", "let scripts write files under `path` (repeatable)")
+	allowEnv := flag.Bool("allow-env", false, "let scripts read environment variables")
+	optimize := flag.Bool("O", false, "optimize the compiled bytecode")
+	dumpIR := flag.Bool("dump-ir", false, "print the intermediate representation of each input before running it")
+	flag.Parse()
+
+	host := &object.Host{
+		Args:       flag.Args(),
+		ReadPaths:  readPaths,
+		WritePaths: writePaths,
+	}
+	if host.Args == nil {
+		host.Args = []string{}
+	}
+	if *allowEnv {
+		host.Env = map[string]string{}
+		for _, kv := range os.Environ() {
+			name, value, _ := strings.Cut(kv, "=")
+			host.Env[name] = value
+		}
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -15,5 +55,12 @@ func main() {
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n",
 		user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	var opts []compiler.Option
+	if *optimize {
+		opts = append(opts, compiler.WithOptimizations())
+	}
+	if *dumpIR {
+		opts = append(opts, compiler.WithIRDump(os.Stdout))
+	}
+	repl.Start(os.Stdin, os.Stdout, host, opts...)
 }
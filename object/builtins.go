@@ -0,0 +1,224 @@
+package object
+
+import "fmt"
+
+// Builtins lists the builtin functions shared by the evaluator and the VM.
+// The compiler refers to builtins by their position in this slice, so new
+// builtins must be appended.
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{"len", &Builtin{Fn: builtinLen}},
+	{"puts", &Builtin{Fn: builtinPuts}},
+	{"first", &Builtin{Fn: builtinFirst}},
+	{"last", &Builtin{Fn: builtinLast}},
+	{"rest", &Builtin{Fn: builtinRest}},
+	{"push", &Builtin{Fn: builtinPush}},
+	{"map", &Builtin{Fn: builtinMap}},
+	{"filter", &Builtin{Fn: builtinFilter}},
+	{"reduce", &Builtin{Fn: builtinReduce}},
+	{"sort", &Builtin{Fn: builtinSort}},
+	{"reverse", &Builtin{Fn: builtinReverse}},
+	{"contains", &Builtin{Fn: builtinContains}},
+	{"index_of", &Builtin{Fn: builtinIndexOf}},
+	{"range", &Builtin{Fn: builtinRange}},
+	{"zip", &Builtin{Fn: builtinZip}},
+	{"keys", &Builtin{Fn: builtinKeys}},
+	{"values", &Builtin{Fn: builtinValues}},
+	{"has_key", &Builtin{Fn: builtinHasKey}},
+	{"delete", &Builtin{Fn: builtinDelete}},
+	{"merge", &Builtin{Fn: builtinMerge}},
+	{"split", &Builtin{Fn: builtinSplit}},
+	{"join", &Builtin{Fn: builtinJoin}},
+	{"trim", &Builtin{Fn: builtinTrim}},
+	{"upper", &Builtin{Fn: builtinUpper}},
+	{"lower", &Builtin{Fn: builtinLower}},
+	{"replace", &Builtin{Fn: builtinReplace}},
+	{"starts_with", &Builtin{Fn: builtinStartsWith}},
+	{"ends_with", &Builtin{Fn: builtinEndsWith}},
+	{"find", &Builtin{Fn: builtinFind}},
+	{"repeat", &Builtin{Fn: builtinRepeat}},
+	{"ord", &Builtin{Fn: builtinOrd}},
+	{"chr", &Builtin{Fn: builtinChr}},
+	{"str", &Builtin{Fn: builtinStr}},
+	{"int", &Builtin{Fn: builtinInt}},
+	{"abs", &Builtin{Fn: builtinAbs}},
+	{"min", &Builtin{Fn: builtinMin}},
+	{"max", &Builtin{Fn: builtinMax}},
+	{"pow", &Builtin{Fn: builtinPow}},
+	{"sqrt", &Builtin{Fn: builtinSqrt}},
+	{"clamp", &Builtin{Fn: builtinClamp}},
+	{"gcd", &Builtin{Fn: builtinGcd}},
+	{"random", &Builtin{Fn: builtinRandom}},
+	{"seed", &Builtin{Fn: builtinSeed}},
+	{"type", &Builtin{Fn: builtinType}},
+	{"is_int", &Builtin{Fn: isType(INTEGER_OBJ)}},
+	{"is_string", &Builtin{Fn: isType(STRING_OBJ)}},
+	{"is_bool", &Builtin{Fn: isType(BOOLEAN_OBJ)}},
+	{"is_array", &Builtin{Fn: isType(ARRAY_OBJ)}},
+	{"is_hash", &Builtin{Fn: isType(HASH_OBJ)}},
+	{"is_null", &Builtin{Fn: isType(NULL_OBJ)}},
+	{"is_function", &Builtin{Fn: isType(FUNCTION_OBJ, BUILTIN_OBJ)}},
+	{"to_int", &Builtin{Fn: builtinToInt}},
+	{"to_string", &Builtin{Fn: builtinToString}},
+	{"to_bool", &Builtin{Fn: builtinToBool}},
+	{"to_array", &Builtin{Fn: builtinToArray}},
+	{"json_encode", &Builtin{Fn: builtinJSONEncode}},
+	{"json_decode", &Builtin{Fn: builtinJSONDecode}},
+	{"read_file", &Builtin{Fn: builtinReadFile}},
+	{"write_file", &Builtin{Fn: builtinWriteFile}},
+	{"list_dir", &Builtin{Fn: builtinListDir}},
+	{"read_line", &Builtin{Fn: builtinReadLine}},
+	{"args", &Builtin{Fn: builtinArgs}},
+	{"env", &Builtin{Fn: builtinEnv}},
+}
+
+func GetBuiltinByName(name string) *Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+	return nil
+}
+
+func builtinLen(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Array:
+		return &Integer{Value: int64(len(arg.Elements))}
+	case *String:
+		return &Integer{Value: int64(arg.Len())}
+	default:
+		return newError("argument to `len` not supported, got %s",
+			args[0].Type())
+	}
+}
+
+// builtinPuts prints each argument on its own line to the host's Stdout.
+func builtinPuts(rt Runtime, args ...Object) Object {
+	out := hostOf(rt).stdout()
+	for _, arg := range args {
+		fmt.Fprintln(out, arg.Inspect())
+	}
+
+	return NULL
+}
+
+func builtinFirst(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	if args[0].Type() != ARRAY_OBJ {
+		return newError("argument to `first` must be ARRAY, got %s",
+			args[0].Type())
+	}
+
+	arr := args[0].(*Array)
+	if len(arr.Elements) > 0 {
+		return arr.Elements[0]
+	}
+
+	return NULL
+}
+
+func builtinLast(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	if args[0].Type() != ARRAY_OBJ {
+		return newError("argument to `last` must be ARRAY, got %s",
+			args[0].Type())
+	}
+
+	arr := args[0].(*Array)
+	length := len(arr.Elements)
+	if length > 0 {
+		return arr.Elements[length-1]
+	}
+
+	return NULL
+}
+
+func builtinRest(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	if args[0].Type() != ARRAY_OBJ {
+		return newError("argument to `rest` must be ARRAY, got %s",
+			args[0].Type())
+	}
+
+	arr := args[0].(*Array)
+	length := len(arr.Elements)
+	if length > 0 {
+		newElements := make([]Object, length-1, length-1)
+		copy(newElements, arr.Elements[1:length])
+		return alloc(rt, &Array{Elements: newElements})
+	}
+
+	return NULL
+}
+
+func builtinPush(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	if args[0].Type() != ARRAY_OBJ {
+		return newError("argument to `push` must be ARRAY, got %s",
+			args[0].Type())
+	}
+
+	arr := args[0].(*Array)
+	length := len(arr.Elements)
+
+	newElements := make([]Object, length+1, length+1)
+	copy(newElements, arr.Elements)
+	newElements[length] = args[1]
+
+	return alloc(rt, &Array{Elements: newElements})
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func isError(obj Object) bool {
+	return obj != nil && obj.Type() == ERROR_OBJ
+}
+
+func isTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return obj.Value
+	case *Null:
+		return false
+	default:
+		return true
+	}
+}
+
+func nativeBoolToBooleanObject(input bool) *Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+func isCallable(obj Object) bool {
+	switch obj.Type() {
+	case FUNCTION_OBJ, CLOSURE_OBJ, BUILTIN_OBJ:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,432 @@
+package object
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// The collection builtins never modify their arguments; they return new
+// arrays and hashes instead, like push and rest.
+
+func builtinMap(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	arr, errObj := arrayAndFunction("map", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	mapped := make([]Object, len(arr.Elements))
+	for i, el := range arr.Elements {
+		result := rt.Call(args[1], el)
+		if isError(result) {
+			return result
+		}
+		mapped[i] = result
+	}
+
+	return alloc(rt, &Array{Elements: mapped})
+}
+
+func builtinFilter(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	arr, errObj := arrayAndFunction("filter", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	filtered := []Object{}
+	for _, el := range arr.Elements {
+		result := rt.Call(args[1], el)
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) {
+			filtered = append(filtered, el)
+		}
+	}
+
+	return alloc(rt, &Array{Elements: filtered})
+}
+
+// builtinReduce is reduce(array, initial, fn), calling fn(accumulator,
+// element) for each element in order.
+func builtinReduce(rt Runtime, args ...Object) Object {
+	if len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=3",
+			len(args))
+	}
+	arr, errObj := arrayAndFunction("reduce", []Object{args[0], args[2]})
+	if errObj != nil {
+		return errObj
+	}
+
+	accumulator := args[1]
+	for _, el := range arr.Elements {
+		accumulator = rt.Call(args[2], accumulator, el)
+		if isError(accumulator) {
+			return accumulator
+		}
+	}
+
+	return accumulator
+}
+
+// builtinSort is sort(array) or sort(array, less). Without less, the
+// elements must be all integers or all strings. less(a, b) returns whether
+// a goes before b. The sort is stable.
+func builtinSort(rt Runtime, args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2",
+			len(args))
+	}
+	if args[0].Type() != ARRAY_OBJ {
+		return newError("argument to `sort` must be ARRAY, got %s",
+			args[0].Type())
+	}
+
+	elements := append([]Object{}, args[0].(*Array).Elements...)
+
+	if len(args) == 1 {
+		for _, el := range elements[min(1, len(elements)):] {
+			if !comparable(elements[0], el) {
+				return newError("cannot compare %s and %s in `sort`",
+					elements[0].Type(), el.Type())
+			}
+		}
+
+		sort.SliceStable(elements, func(i, j int) bool {
+			return less(elements[i], elements[j])
+		})
+		return alloc(rt, &Array{Elements: elements})
+	}
+
+	if !isCallable(args[1]) {
+		return newError("comparator of `sort` must be a function, got %s",
+			args[1].Type())
+	}
+
+	var failed Object
+	sort.SliceStable(elements, func(i, j int) bool {
+		if failed != nil {
+			return false
+		}
+		result := rt.Call(args[1], elements[i], elements[j])
+		if isError(result) {
+			failed = result
+			return false
+		}
+		return isTruthy(result)
+	})
+	if failed != nil {
+		return failed
+	}
+
+	return alloc(rt, &Array{Elements: elements})
+}
+
+func comparable(a, b Object) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	return a.Type() == INTEGER_OBJ || a.Type() == STRING_OBJ
+}
+
+func less(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value < b.(*Integer).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	default:
+		return false
+	}
+}
+
+func builtinReverse(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Array:
+		length := len(arg.Elements)
+		reversed := make([]Object, length)
+		for i, el := range arg.Elements {
+			reversed[length-1-i] = el
+		}
+		return alloc(rt, &Array{Elements: reversed})
+	case *String:
+		runes := []rune(arg.Value)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return alloc(rt, &String{Value: string(runes)})
+	default:
+		return newError("argument to `reverse` not supported, got %s",
+			args[0].Type())
+	}
+}
+
+// builtinContains reports whether an array has an element equal to the
+// value, or whether a string contains the substring.
+func builtinContains(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Array:
+		return nativeBoolToBooleanObject(indexOf(arg, args[1]) >= 0)
+	case *String:
+		substr, ok := args[1].(*String)
+		if !ok {
+			return newError("argument to `contains` must be STRING, got %s",
+				args[1].Type())
+		}
+		return nativeBoolToBooleanObject(strings.Contains(arg.Value, substr.Value))
+	default:
+		return newError("argument to `contains` not supported, got %s",
+			args[0].Type())
+	}
+}
+
+// builtinIndexOf returns the index of the first element equal to the
+// value, or -1 if there is none.
+func builtinIndexOf(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	if args[0].Type() != ARRAY_OBJ {
+		return newError("argument to `index_of` must be ARRAY, got %s",
+			args[0].Type())
+	}
+
+	return &Integer{Value: int64(indexOf(args[0].(*Array), args[1]))}
+}
+
+func indexOf(arr *Array, value Object) int {
+	for i, el := range arr.Elements {
+		if Equals(el, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// builtinRange is range(end), range(start, end) or range(start, end, step).
+// Like slices, the range includes start and excludes end.
+func builtinRange(rt Runtime, args ...Object) Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError("wrong number of arguments. got=%d, want=1, 2 or 3",
+			len(args))
+	}
+
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		integer, ok := arg.(*Integer)
+		if !ok {
+			return newError("argument to `range` must be INTEGER, got %s",
+				arg.Type())
+		}
+		bounds[i] = integer.Value
+	}
+
+	start, end, step := int64(0), bounds[0], int64(1)
+	if len(bounds) > 1 {
+		start, end = bounds[0], bounds[1]
+	}
+	if len(bounds) > 2 {
+		step = bounds[2]
+	}
+	if step == 0 {
+		return newError("step of `range` must not be zero")
+	}
+
+	if err := reserve(rt, headerSize*rangeLength(start, end, step)); err != nil {
+		return err
+	}
+
+	elements := []Object{}
+	for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+		elements = append(elements, &Integer{Value: i})
+	}
+
+	return alloc(rt, &Array{Elements: elements})
+}
+
+// rangeLength returns the number of elements of a range, saturating at
+// the largest array that could be allocated.
+func rangeLength(start, end, step int64) int64 {
+	var distance, stride uint64
+	switch {
+	case step > 0 && end > start:
+		distance, stride = uint64(end)-uint64(start), uint64(step)
+	case step < 0 && end < start:
+		distance, stride = uint64(start)-uint64(end), -uint64(step)
+	default:
+		return 0
+	}
+
+	n := (distance-1)/stride + 1
+	if n > math.MaxInt64/headerSize {
+		return math.MaxInt64 / headerSize
+	}
+	return int64(n)
+}
+
+// builtinZip pairs up the elements of two arrays, stopping at the end of
+// the shorter one.
+func builtinZip(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	for _, arg := range args {
+		if arg.Type() != ARRAY_OBJ {
+			return newError("argument to `zip` must be ARRAY, got %s",
+				arg.Type())
+		}
+	}
+
+	left := args[0].(*Array).Elements
+	right := args[1].(*Array).Elements
+
+	pairs := make([]Object, min(len(left), len(right)))
+	for i := range pairs {
+		pairs[i] = &Array{Elements: []Object{left[i], right[i]}}
+	}
+
+	return alloc(rt, &Array{Elements: pairs})
+}
+
+func builtinKeys(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	if args[0].Type() != HASH_OBJ {
+		return newError("argument to `keys` must be HASH, got %s",
+			args[0].Type())
+	}
+
+	pairs := args[0].(*Hash).Pairs()
+	keys := make([]Object, len(pairs))
+	for i, pair := range pairs {
+		keys[i] = pair.Key
+	}
+
+	return alloc(rt, &Array{Elements: keys})
+}
+
+func builtinValues(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	if args[0].Type() != HASH_OBJ {
+		return newError("argument to `values` must be HASH, got %s",
+			args[0].Type())
+	}
+
+	pairs := args[0].(*Hash).Pairs()
+	values := make([]Object, len(pairs))
+	for i, pair := range pairs {
+		values[i] = pair.Value
+	}
+
+	return alloc(rt, &Array{Elements: values})
+}
+
+func builtinHasKey(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	hash, key, errObj := hashAndKey("has_key", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	_, ok := hash.Get(key)
+	return nativeBoolToBooleanObject(ok)
+}
+
+// builtinDelete returns a copy of the hash without the key.
+func builtinDelete(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	hash, key, errObj := hashAndKey("delete", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	result := NewHash()
+	for _, pair := range hash.Pairs() {
+		if !Equals(pair.Key, key) {
+			result.Set(pair.Key.(Hashable), pair.Value)
+		}
+	}
+
+	return alloc(rt, result)
+}
+
+// builtinMerge returns a new hash with the pairs of both hashes. Values of
+// the second hash win when both have the same key.
+func builtinMerge(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+
+	result := NewHash()
+	for _, arg := range args {
+		hash, ok := arg.(*Hash)
+		if !ok {
+			return newError("argument to `merge` must be HASH, got %s",
+				arg.Type())
+		}
+		for _, pair := range hash.Pairs() {
+			result.Set(pair.Key.(Hashable), pair.Value)
+		}
+	}
+
+	return alloc(rt, result)
+}
+
+func arrayAndFunction(name string, args []Object) (*Array, Object) {
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return nil, newError("argument to `%s` must be ARRAY, got %s",
+			name, args[0].Type())
+	}
+	if !isCallable(args[1]) {
+		return nil, newError("callback of `%s` must be a function, got %s",
+			name, args[1].Type())
+	}
+	return arr, nil
+}
+
+func hashAndKey(name string, args []Object) (*Hash, Hashable, Object) {
+	hash, ok := args[0].(*Hash)
+	if !ok {
+		return nil, nil, newError("argument to `%s` must be HASH, got %s",
+			name, args[0].Type())
+	}
+	key, ok := args[1].(Hashable)
+	if !ok {
+		return nil, nil, newError("unusable as hash key: %s", args[1].Type())
+	}
+	return hash, key, nil
+}
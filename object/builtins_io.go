@@ -0,0 +1,171 @@
+package object
+
+import (
+	"os"
+	"sort"
+)
+
+// The I/O builtins can only reach what the Host of the running engine
+// grants; see Host.
+
+func builtinReadFile(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	path, ok := args[0].(*String)
+	if !ok {
+		return newError("argument to `read_file` must be STRING, got %s",
+			args[0].Type())
+	}
+
+	resolved, err := checkPath("read_file", hostOf(rt).ReadPaths, path.Value, false)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	content, err := os.ReadFile(resolved)
+	if err != nil {
+		return newError("read_file: %s", err)
+	}
+
+	return alloc(rt, &String{Value: string(content)})
+}
+
+// builtinWriteFile replaces the content of the file, creating it if
+// needed.
+func builtinWriteFile(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	strs, errObj := stringArguments("write_file", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	resolved, err := checkPath("write_file", hostOf(rt).WritePaths, strs[0], true)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	err = os.WriteFile(resolved, []byte(strs[1]), 0644)
+	if err != nil {
+		return newError("write_file: %s", err)
+	}
+
+	return NULL
+}
+
+// builtinListDir returns the names of the entries of a directory, sorted.
+func builtinListDir(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	path, ok := args[0].(*String)
+	if !ok {
+		return newError("argument to `list_dir` must be STRING, got %s",
+			args[0].Type())
+	}
+
+	resolved, err := checkPath("list_dir", hostOf(rt).ReadPaths, path.Value, false)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	entries, err := os.ReadDir(resolved)
+	if err != nil {
+		return newError("list_dir: %s", err)
+	}
+
+	names := make([]Object, len(entries))
+	for i, entry := range entries {
+		names[i] = &String{Value: entry.Name()}
+	}
+
+	return alloc(rt, &Array{Elements: names})
+}
+
+// builtinReadLine returns the next line of the host's input, or null at
+// the end of the input.
+func builtinReadLine(rt Runtime, args ...Object) Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0",
+			len(args))
+	}
+
+	host := hostOf(rt)
+	if host.Stdin == nil {
+		return newError("read_line: reading input is not permitted")
+	}
+
+	line, ok, err := host.readLine()
+	if err != nil {
+		return newError("read_line: %s", err)
+	}
+	if !ok {
+		return NULL
+	}
+
+	return alloc(rt, &String{Value: line})
+}
+
+func builtinArgs(rt Runtime, args ...Object) Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0",
+			len(args))
+	}
+
+	host := hostOf(rt)
+	if host.Args == nil {
+		return newError("args: reading arguments is not permitted")
+	}
+
+	elements := make([]Object, len(host.Args))
+	for i, arg := range host.Args {
+		elements[i] = &String{Value: arg}
+	}
+
+	return alloc(rt, &Array{Elements: elements})
+}
+
+// builtinEnv is env(), a hash of the granted variables sorted by name, or
+// env(name), the value of one variable or null if it is not set.
+func builtinEnv(rt Runtime, args ...Object) Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1",
+			len(args))
+	}
+
+	host := hostOf(rt)
+	if host.Env == nil {
+		return newError("env: reading the environment is not permitted")
+	}
+
+	if len(args) == 1 {
+		name, ok := args[0].(*String)
+		if !ok {
+			return newError("argument to `env` must be STRING, got %s",
+				args[0].Type())
+		}
+		value, ok := host.Env[name.Value]
+		if !ok {
+			return NULL
+		}
+		return alloc(rt, &String{Value: value})
+	}
+
+	names := make([]string, 0, len(host.Env))
+	for name := range host.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := NewHash()
+	for _, name := range names {
+		hash.Set(&String{Value: name}, &String{Value: host.Env[name]})
+	}
+
+	return alloc(rt, hash)
+}
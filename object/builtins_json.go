@@ -0,0 +1,229 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JSON objects map to hashes with string keys. Hashes keep their insertion
+// order, so json_encode writes keys in the order they were added and
+// json_decode adds them in the order they appear in the input.
+
+// builtinJSONEncode is json_encode(value) or json_encode(value, pretty).
+func builtinJSONEncode(rt Runtime, args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2",
+			len(args))
+	}
+
+	pretty := false
+	if len(args) == 2 {
+		b, ok := args[1].(*Boolean)
+		if !ok {
+			return newError("argument to `json_encode` must be BOOLEAN, got %s",
+				args[1].Type())
+		}
+		pretty = b.Value
+	}
+
+	e := &jsonEncoder{visiting: make(map[Object]bool)}
+	if err := e.encode(args[0], "$"); err != nil {
+		return newError("json_encode: %s", err)
+	}
+
+	if !pretty {
+		return alloc(rt, &String{Value: e.out.String()})
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, e.out.Bytes(), "", "  "); err != nil {
+		return newError("json_encode: %s", err)
+	}
+	return alloc(rt, &String{Value: indented.String()})
+}
+
+type jsonEncoder struct {
+	out      bytes.Buffer
+	visiting map[Object]bool
+}
+
+// encode writes obj as compact JSON. path locates obj within the encoded
+// value for error messages, e.g. $.users[2].name.
+func (e *jsonEncoder) encode(obj Object, path string) error {
+	switch obj := obj.(type) {
+	case *Null:
+		e.out.WriteString("null")
+	case *Boolean:
+		fmt.Fprintf(&e.out, "%t", obj.Value)
+	case *Integer:
+		fmt.Fprintf(&e.out, "%d", obj.Value)
+	case *String:
+		e.writeString(obj.Value)
+	case *Array:
+		if e.visiting[obj] {
+			return fmt.Errorf("cyclic value at %s", path)
+		}
+		e.visiting[obj] = true
+		defer delete(e.visiting, obj)
+
+		e.out.WriteString("[")
+		for i, el := range obj.Elements {
+			if i > 0 {
+				e.out.WriteString(",")
+			}
+			if err := e.encode(el, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		e.out.WriteString("]")
+	case *Hash:
+		if e.visiting[obj] {
+			return fmt.Errorf("cyclic value at %s", path)
+		}
+		e.visiting[obj] = true
+		defer delete(e.visiting, obj)
+
+		e.out.WriteString("{")
+		for i, pair := range obj.Pairs() {
+			key, ok := pair.Key.(*String)
+			if !ok {
+				return fmt.Errorf("cannot encode %s key %s at %s, keys must be STRING",
+					pair.Key.Type(), pair.Key.Inspect(), path)
+			}
+			if i > 0 {
+				e.out.WriteString(",")
+			}
+			e.writeString(key.Value)
+			e.out.WriteString(":")
+			if err := e.encode(pair.Value, path+"."+key.Value); err != nil {
+				return err
+			}
+		}
+		e.out.WriteString("}")
+	default:
+		return fmt.Errorf("cannot encode %s at %s", typeName(obj), path)
+	}
+
+	return nil
+}
+
+func (e *jsonEncoder) writeString(s string) {
+	enc := json.NewEncoder(&e.out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	// Encode terminates each value with a newline.
+	e.out.Truncate(e.out.Len() - 1)
+}
+
+func builtinJSONDecode(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	str, ok := args[0].(*String)
+	if !ok {
+		return newError("argument to `json_decode` must be STRING, got %s",
+			args[0].Type())
+	}
+
+	dec := json.NewDecoder(strings.NewReader(str.Value))
+	dec.UseNumber()
+
+	result, err := decodeJSON(dec)
+	if err != nil {
+		return newError("json_decode: %s", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return newError("json_decode: unexpected data after JSON value")
+	}
+
+	return alloc(rt, result)
+}
+
+// decodeJSON reads the next value token by token rather than through
+// json.Unmarshal, so that object keys keep their order.
+func decodeJSON(dec *json.Decoder) (Object, error) {
+	tok, err := nextJSONToken(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return NULL, nil
+	case bool:
+		return nativeBoolToBooleanObject(tok), nil
+	case string:
+		return &String{Value: tok}, nil
+	case json.Number:
+		value, err := tok.Int64()
+		if err != nil && strings.ContainsAny(tok.String(), ".eE") {
+			return nil, fmt.Errorf("number %s is not an integer", tok)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("number %s is out of range", tok)
+		}
+		return &Integer{Value: value}, nil
+	case json.Delim:
+		if tok == '[' {
+			return decodeJSONArray(dec)
+		}
+		return decodeJSONObject(dec)
+	}
+
+	return nil, fmt.Errorf("unexpected token %v", tok)
+}
+
+func decodeJSONArray(dec *json.Decoder) (Object, error) {
+	elements := []Object{}
+	for dec.More() {
+		el, err := decodeJSON(dec)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, el)
+	}
+
+	// the closing ]
+	if _, err := nextJSONToken(dec); err != nil {
+		return nil, err
+	}
+
+	return &Array{Elements: elements}, nil
+}
+
+func decodeJSONObject(dec *json.Decoder) (Object, error) {
+	hash := NewHash()
+	for dec.More() {
+		tok, err := nextJSONToken(dec)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := decodeJSON(dec)
+		if err != nil {
+			return nil, err
+		}
+
+		hash.Set(&String{Value: tok.(string)}, value)
+	}
+
+	// the closing }
+	if _, err := nextJSONToken(dec); err != nil {
+		return nil, err
+	}
+
+	return hash, nil
+}
+
+func nextJSONToken(dec *json.Decoder) (json.Token, error) {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return nil, errors.New("unexpected end of JSON input")
+	}
+	return tok, err
+}
@@ -0,0 +1,252 @@
+package object
+
+import (
+	"math"
+	"math/rand"
+)
+
+// DefaultSeed seeds random until a program calls seed, so that runs are
+// reproducible by default.
+const DefaultSeed = 1
+
+// NewRandom returns a generator for the random builtin seeded with
+// DefaultSeed. Every engine owns one, so seed in one program never
+// changes the numbers of another.
+func NewRandom() *rand.Rand {
+	return rand.New(rand.NewSource(DefaultSeed))
+}
+
+// randomRuntime is implemented by runtimes that own a generator for the
+// random builtin.
+type randomRuntime interface {
+	Random() *rand.Rand
+}
+
+// randomOf returns the generator of the engine running a builtin.
+// Runtimes without one get a fresh generator on every call.
+func randomOf(rt Runtime) *rand.Rand {
+	if r, ok := rt.(randomRuntime); ok && r.Random() != nil {
+		return r.Random()
+	}
+	return NewRandom()
+}
+
+func builtinAbs(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	values, errObj := integerArguments("abs", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	value := values[0]
+	if value == math.MinInt64 {
+		return newError("integer overflow in `abs`")
+	}
+	if value < 0 {
+		value = -value
+	}
+
+	return &Integer{Value: value}
+}
+
+// builtinMin is min(a, b, ...) or min(array).
+func builtinMin(rt Runtime, args ...Object) Object {
+	return extremum("min", args, func(a, b int64) bool { return a < b })
+}
+
+// builtinMax is max(a, b, ...) or max(array).
+func builtinMax(rt Runtime, args ...Object) Object {
+	return extremum("max", args, func(a, b int64) bool { return a > b })
+}
+
+func extremum(name string, args []Object, better func(a, b int64) bool) Object {
+	if len(args) == 1 {
+		if arr, ok := args[0].(*Array); ok {
+			args = arr.Elements
+			if len(args) == 0 {
+				return newError("argument to `%s` must not be empty", name)
+			}
+		}
+	}
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=%d, want=1 or more",
+			len(args))
+	}
+	values, errObj := integerArguments(name, args)
+	if errObj != nil {
+		return errObj
+	}
+
+	result := values[0]
+	for _, value := range values[1:] {
+		if better(value, result) {
+			result = value
+		}
+	}
+
+	return &Integer{Value: result}
+}
+
+// builtinPow raises an integer to a non-negative integer power.
+func builtinPow(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	values, errObj := integerArguments("pow", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	base, exp := values[0], values[1]
+	if exp < 0 {
+		return newError("exponent of `pow` must not be negative, got %d", exp)
+	}
+
+	result := int64(1)
+	for ; exp > 0; exp-- {
+		if overflowsMul(result, base) {
+			return newError("integer overflow in `pow`")
+		}
+		result *= base
+	}
+
+	return &Integer{Value: result}
+}
+
+func overflowsMul(a, b int64) bool {
+	if a == 0 || b == 0 {
+		return false
+	}
+	product := a * b
+	return product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64)
+}
+
+// builtinSqrt returns the integer square root, rounded down.
+func builtinSqrt(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	values, errObj := integerArguments("sqrt", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	value := values[0]
+	if value < 0 {
+		return newError("argument to `sqrt` must not be negative, got %d", value)
+	}
+
+	// float64 cannot represent every int64, so correct the estimate. The
+	// squares fit in a uint64.
+	n := uint64(value)
+	root := uint64(math.Sqrt(float64(value)))
+	for root*root > n {
+		root--
+	}
+	for (root+1)*(root+1) <= n {
+		root++
+	}
+
+	return &Integer{Value: int64(root)}
+}
+
+// builtinClamp is clamp(x, low, high).
+func builtinClamp(rt Runtime, args ...Object) Object {
+	if len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=3",
+			len(args))
+	}
+	values, errObj := integerArguments("clamp", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	value, low, high := values[0], values[1], values[2]
+	if low > high {
+		return newError("bounds of `clamp` are reversed: %d > %d", low, high)
+	}
+
+	return &Integer{Value: max(low, min(value, high))}
+}
+
+// builtinGcd returns the non-negative greatest common divisor.
+func builtinGcd(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	values, errObj := integerArguments("gcd", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	a, b := values[0], values[1]
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a == math.MinInt64 {
+		return newError("integer overflow in `gcd`")
+	}
+	if a < 0 {
+		a = -a
+	}
+
+	return &Integer{Value: a}
+}
+
+// builtinRandom is random(n), an integer in [0, n), or random(low, high),
+// an integer in [low, high).
+func builtinRandom(rt Runtime, args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2",
+			len(args))
+	}
+	values, errObj := integerArguments("random", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	low, high := int64(0), values[0]
+	if len(values) == 2 {
+		low, high = values[0], values[1]
+	}
+	if high <= low || high-low <= 0 {
+		return newError("range of `random` is empty: [%d, %d)", low, high)
+	}
+
+	return &Integer{Value: low + randomOf(rt).Int63n(high-low)}
+}
+
+// builtinSeed is seed(n), which makes the following random numbers
+// reproducible.
+func builtinSeed(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	values, errObj := integerArguments("seed", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	randomOf(rt).Seed(values[0])
+	return NULL
+}
+
+func integerArguments(name string, args []Object) ([]int64, Object) {
+	values := make([]int64, len(args))
+	for i, arg := range args {
+		integer, ok := arg.(*Integer)
+		if !ok {
+			return nil, newError("argument to `%s` must be INTEGER, got %s",
+				name, arg.Type())
+		}
+		values[i] = integer.Value
+	}
+	return values, nil
+}
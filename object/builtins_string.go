@@ -0,0 +1,264 @@
+package object
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Like indexing, the string builtins count positions in runes.
+
+func builtinSplit(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	strs, errObj := stringArguments("split", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	parts := strings.Split(strs[0], strs[1])
+	elements := make([]Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &String{Value: part}
+	}
+
+	return alloc(rt, &Array{Elements: elements})
+}
+
+func builtinJoin(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return newError("argument to `join` must be ARRAY, got %s",
+			args[0].Type())
+	}
+	sep, ok := args[1].(*String)
+	if !ok {
+		return newError("argument to `join` must be STRING, got %s",
+			args[1].Type())
+	}
+
+	parts := make([]string, len(arr.Elements))
+	for i, el := range arr.Elements {
+		str, ok := el.(*String)
+		if !ok {
+			return newError("elements joined by `join` must be STRING, got %s",
+				el.Type())
+		}
+		parts[i] = str.Value
+	}
+
+	return alloc(rt, &String{Value: strings.Join(parts, sep.Value)})
+}
+
+func builtinTrim(rt Runtime, args ...Object) Object {
+	return alloc(rt, mapString("trim", strings.TrimSpace, args))
+}
+
+func builtinUpper(rt Runtime, args ...Object) Object {
+	return alloc(rt, mapString("upper", strings.ToUpper, args))
+}
+
+func builtinLower(rt Runtime, args ...Object) Object {
+	return alloc(rt, mapString("lower", strings.ToLower, args))
+}
+
+// builtinReplace replaces every occurrence of old with new.
+func builtinReplace(rt Runtime, args ...Object) Object {
+	if len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=3",
+			len(args))
+	}
+	strs, errObj := stringArguments("replace", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	return alloc(rt, &String{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])})
+}
+
+func builtinStartsWith(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	strs, errObj := stringArguments("starts_with", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	return nativeBoolToBooleanObject(strings.HasPrefix(strs[0], strs[1]))
+}
+
+func builtinEndsWith(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	strs, errObj := stringArguments("ends_with", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	return nativeBoolToBooleanObject(strings.HasSuffix(strs[0], strs[1]))
+}
+
+// builtinFind returns the index of the first occurrence of the substring,
+// or -1 if there is none.
+func builtinFind(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	strs, errObj := stringArguments("find", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	return &Integer{Value: int64(findString(strs[0], strs[1]))}
+}
+
+func findString(s, substr string) int {
+	i := strings.Index(s, substr)
+	if i < 0 {
+		return -1
+	}
+	return utf8.RuneCountInString(s[:i])
+}
+
+func builtinRepeat(rt Runtime, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	str, ok := args[0].(*String)
+	if !ok {
+		return newError("argument to `repeat` must be STRING, got %s",
+			args[0].Type())
+	}
+	count, ok := args[1].(*Integer)
+	if !ok {
+		return newError("argument to `repeat` must be INTEGER, got %s",
+			args[1].Type())
+	}
+	if count.Value < 0 {
+		return newError("count of `repeat` must not be negative, got %d",
+			count.Value)
+	}
+
+	size := int64(math.MaxInt64)
+	if count.Value == 0 || int64(len(str.Value)) <= math.MaxInt64/count.Value {
+		size = int64(len(str.Value)) * count.Value
+	}
+	if err := reserve(rt, size); err != nil {
+		return err
+	}
+
+	return alloc(rt, &String{Value: strings.Repeat(str.Value, int(count.Value))})
+}
+
+// builtinOrd returns the code point of a one-character string.
+func builtinOrd(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	str, ok := args[0].(*String)
+	if !ok {
+		return newError("argument to `ord` must be STRING, got %s",
+			args[0].Type())
+	}
+	if str.Len() != 1 {
+		return newError("argument to `ord` must be a single character, got %q",
+			str.Value)
+	}
+
+	r, _ := utf8.DecodeRuneInString(str.Value)
+	return &Integer{Value: int64(r)}
+}
+
+// builtinChr returns the one-character string of a code point.
+func builtinChr(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	code, ok := args[0].(*Integer)
+	if !ok {
+		return newError("argument to `chr` must be INTEGER, got %s",
+			args[0].Type())
+	}
+	if code.Value < 0 || code.Value > utf8.MaxRune || !utf8.ValidRune(rune(code.Value)) {
+		return newError("invalid code point: %d", code.Value)
+	}
+
+	return alloc(rt, &String{Value: string(rune(code.Value))})
+}
+
+// builtinStr converts any value to its string form, as interpolation does.
+func builtinStr(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	if str, ok := args[0].(*String); ok {
+		return str
+	}
+
+	return alloc(rt, &String{Value: args[0].Inspect()})
+}
+
+// builtinInt parses a decimal integer, optionally signed and surrounded by
+// whitespace.
+func builtinInt(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer:
+		return arg
+	case *String:
+		value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+		if err != nil {
+			return newError("could not parse %q as integer", arg.Value)
+		}
+		return &Integer{Value: value}
+	default:
+		return newError("argument to `int` not supported, got %s",
+			args[0].Type())
+	}
+}
+
+func mapString(name string, f func(string) string, args []Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	strs, errObj := stringArguments(name, args)
+	if errObj != nil {
+		return errObj
+	}
+
+	return &String{Value: f(strs[0])}
+}
+
+func stringArguments(name string, args []Object) ([]string, Object) {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*String)
+		if !ok {
+			return nil, newError("argument to `%s` must be STRING, got %s",
+				name, arg.Type())
+		}
+		strs[i] = str.Value
+	}
+	return strs, nil
+}
@@ -0,0 +1,108 @@
+package object
+
+// typeName is the type of obj as scripts see it. Functions are FUNCTION in
+// both engines, although the VM represents them as closures.
+func typeName(obj Object) ObjectType {
+	switch obj.Type() {
+	case CLOSURE_OBJ, COMPILED_FUNCTION_OBJ:
+		return FUNCTION_OBJ
+	default:
+		return obj.Type()
+	}
+}
+
+func builtinType(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	return alloc(rt, &String{Value: string(typeName(args[0]))})
+}
+
+// isType returns a predicate builtin reporting whether its argument has one
+// of the types.
+func isType(types ...ObjectType) BuiltinFunction {
+	return func(rt Runtime, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1",
+				len(args))
+		}
+
+		for _, t := range types {
+			if typeName(args[0]) == t {
+				return TRUE
+			}
+		}
+		return FALSE
+	}
+}
+
+// builtinToInt converts integers, decimal strings and booleans.
+func builtinToInt(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Boolean:
+		if arg.Value {
+			return &Integer{Value: 1}
+		}
+		return &Integer{Value: 0}
+	case *Integer, *String:
+		return builtinInt(rt, arg)
+	default:
+		return newError("cannot convert %s to INTEGER", typeName(arg))
+	}
+}
+
+func builtinToString(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	return builtinStr(rt, args...)
+}
+
+// builtinToBool returns the truthiness of any value, as if uses it.
+func builtinToBool(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(args[0]))
+}
+
+// builtinToArray converts strings to their characters, hashes to [key,
+// value] pairs and null to an empty array.
+func builtinToArray(rt Runtime, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *Array:
+		return arg
+	case *String:
+		elements := []Object{}
+		for _, r := range arg.Value {
+			elements = append(elements, &String{Value: string(r)})
+		}
+		return alloc(rt, &Array{Elements: elements})
+	case *Hash:
+		elements := make([]Object, arg.Len())
+		for i, pair := range arg.Pairs() {
+			elements[i] = &Array{Elements: []Object{pair.Key, pair.Value}}
+		}
+		return alloc(rt, &Array{Elements: elements})
+	case *Null:
+		return alloc(rt, &Array{Elements: []Object{}})
+	default:
+		return newError("cannot convert %s to ARRAY", typeName(arg))
+	}
+}
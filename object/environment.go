@@ -1,5 +1,7 @@
 package object
 
+import "math/rand"
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
@@ -14,6 +16,91 @@ func NewEnvironment() *Environment {
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+	host  *Host
+
+	limits *Limits
+	random *rand.Rand
+
+	// number of function calls the evaluation is nested in
+	depth int
+	// number of nested calls allowed, 0 for the default of the evaluator
+	maxDepth int
+}
+
+// SetHost grants the script evaluated in e, and in environments enclosed
+// by it, access to host.
+func (e *Environment) SetHost(host *Host) {
+	e.host = host
+}
+
+// Host returns the Host of the nearest environment that has one.
+func (e *Environment) Host() *Host {
+	for env := e; env != nil; env = env.outer {
+		if env.host != nil {
+			return env.host
+		}
+	}
+	return nil
+}
+
+// SetLimits limits the evaluation of programs in e and environments
+// enclosed by it. nil removes the limits.
+func (e *Environment) SetLimits(limits *Limits) {
+	e.limits = limits
+}
+
+// Limits returns the Limits of the nearest environment that has them.
+func (e *Environment) Limits() *Limits {
+	for env := e; env != nil; env = env.outer {
+		if env.limits != nil {
+			return env.limits
+		}
+	}
+	return nil
+}
+
+// Random returns the generator of the random builtin for programs
+// evaluated in e. Environments enclosed by the same outermost environment
+// share one, created on first use.
+func (e *Environment) Random() *rand.Rand {
+	root := e
+	for root.outer != nil {
+		root = root.outer
+	}
+	if root.random == nil {
+		root.random = NewRandom()
+	}
+	return root.random
+}
+
+// NewCallEnvironment returns the environment of a call to a function
+// defined in outer. caller is the environment the call is made from.
+func NewCallEnvironment(outer, caller *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.depth = caller.depth + 1
+	return env
+}
+
+// SetMaxDepth limits the number of nested function calls of programs
+// evaluated in e and environments enclosed by it. 0 restores the default.
+func (e *Environment) SetMaxDepth(maxDepth int) {
+	e.maxDepth = maxDepth
+}
+
+// MaxDepth returns the limit of the nearest environment that has one, or
+// 0 if none has.
+func (e *Environment) MaxDepth() int {
+	for env := e; env != nil; env = env.outer {
+		if env.maxDepth > 0 {
+			return env.maxDepth
+		}
+	}
+	return 0
+}
+
+// Depth returns the number of function calls evaluating in e is nested in.
+func (e *Environment) Depth() int {
+	return e.depth
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
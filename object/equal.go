@@ -0,0 +1,100 @@
+package object
+
+// Equals reports whether a and b are structurally equal:
+//
+//   - integers, booleans and strings are equal when their values are;
+//   - null is equal only to null;
+//   - arrays are equal when they have equal elements in the same order;
+//   - hashes are equal when they hold equal values for the same keys,
+//     regardless of insertion order;
+//   - any other objects, such as functions, are equal only to themselves.
+//
+// Values of different types are never equal. Arrays and hashes that refer
+// back to themselves are compared without looping forever.
+func Equals(a, b Object) bool {
+	return equals(a, b, nil)
+}
+
+// comparison is a pair of containers whose equality is being decided
+// further up the stack.
+type comparison struct {
+	a, b Object
+}
+
+func equals(a, b Object, inProgress map[comparison]bool) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+
+		inProgress, seen := enter(inProgress, a, b)
+		if seen {
+			return true
+		}
+
+		for i := range a.Elements {
+			if !equals(a.Elements[i], b.Elements[i], inProgress) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+
+		inProgress, seen := enter(inProgress, a, b)
+		if seen {
+			return true
+		}
+
+		for _, pair := range a.Pairs() {
+			value, ok := b.Get(pair.Key.(Hashable))
+			if !ok || !equals(pair.Value, value, inProgress) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// enter records that a and b are being compared. If they already were, the
+// structures are cyclic and the comparison in progress decides the result,
+// so seen is true and the caller should treat this pair as equal.
+func enter(inProgress map[comparison]bool, a, b Object) (map[comparison]bool, bool) {
+	if inProgress == nil {
+		inProgress = make(map[comparison]bool)
+	}
+
+	c := comparison{a, b}
+	if inProgress[c] {
+		return inProgress, true
+	}
+	inProgress[c] = true
+
+	return inProgress, false
+}
@@ -0,0 +1,90 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// HashKey is a compact digest of a hashable object. Different objects may
+// share a HashKey, so it only selects a bucket; keys within a bucket are
+// told apart by comparing the objects themselves.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash keeps its pairs in insertion order, so Inspect and iteration are
+// deterministic, while lookups by HashKey stay O(1) on average.
+type Hash struct {
+	buckets map[HashKey][]int // positions in pairs of the keys with each HashKey
+	pairs   []HashPair
+}
+
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]int)}
+}
+
+// Set adds a pair, or replaces the value of an existing key without
+// changing its position.
+func (h *Hash) Set(key Hashable, value Object) {
+	hashed := key.HashKey()
+
+	if i, ok := h.find(hashed, key); ok {
+		h.pairs[i].Value = value
+		return
+	}
+
+	h.buckets[hashed] = append(h.buckets[hashed], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	i, ok := h.find(key.HashKey(), key)
+	if !ok {
+		return nil, false
+	}
+	return h.pairs[i].Value, true
+}
+
+func (h *Hash) Len() int { return len(h.pairs) }
+
+// Pairs returns the pairs in insertion order. The slice is shared with the
+// hash and must not be modified.
+func (h *Hash) Pairs() []HashPair { return h.pairs }
+
+func (h *Hash) find(hashed HashKey, key Object) (int, bool) {
+	for _, i := range h.buckets[hashed] {
+		if Equals(h.pairs[i].Key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.pairs {
+		pairs = append(pairs, fmt.Sprintf("%s: %s",
+			pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -0,0 +1,113 @@
+package object
+
+import "testing"
+
+func TestHashPreservesInsertionOrder(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&String{Value: "b"}, &Integer{Value: 1})
+	hash.Set(&Integer{Value: 10}, &Integer{Value: 2})
+	hash.Set(&String{Value: "a"}, &Integer{Value: 3})
+	hash.Set(&String{Value: "b"}, &Integer{Value: 4})
+
+	if hash.Len() != 3 {
+		t.Fatalf("hash has wrong length. want=3, got=%d", hash.Len())
+	}
+
+	expected := "{b: 4, 10: 2, a: 3}"
+	if hash.Inspect() != expected {
+		t.Errorf("hash.Inspect() wrong. want=%q, got=%q", expected, hash.Inspect())
+	}
+
+	value, ok := hash.Get(&String{Value: "a"})
+	if !ok || value.Inspect() != "3" {
+		t.Errorf("hash.Get(a) wrong. got=%v, %t", value, ok)
+	}
+
+	if _, ok := hash.Get(&String{Value: "missing"}); ok {
+		t.Errorf("hash.Get(missing) found a value")
+	}
+}
+
+// collidingKey gives every key the same HashKey, so a Hash holding several
+// of them has to tell them apart by comparing the keys themselves.
+type collidingKey struct {
+	name string
+}
+
+func (k *collidingKey) Type() ObjectType { return "COLLIDING" }
+func (k *collidingKey) Inspect() string  { return k.name }
+func (k *collidingKey) HashKey() HashKey {
+	return HashKey{Type: STRING_OBJ, Value: 42}
+}
+
+func TestHashCollidingKeys(t *testing.T) {
+	a := &collidingKey{name: "a"}
+	b := &collidingKey{name: "b"}
+	str := &String{Value: "c"}
+	colliding := &collidingKey{name: "colliding"}
+
+	if a.HashKey() != b.HashKey() {
+		t.Fatalf("test keys do not collide")
+	}
+
+	hash := NewHash()
+	hash.Set(a, &Integer{Value: 1})
+	hash.Set(b, &Integer{Value: 2})
+	hash.Set(str, &Integer{Value: 3})
+
+	if hash.Len() != 3 {
+		t.Fatalf("colliding keys overwrote each other. want=3 pairs, got=%d", hash.Len())
+	}
+
+	tests := []struct {
+		key      Hashable
+		expected int64
+	}{
+		{a, 1},
+		{b, 2},
+		{str, 3},
+		{&String{Value: "c"}, 3},
+	}
+
+	for _, tt := range tests {
+		value, ok := hash.Get(tt.key)
+		if !ok {
+			t.Errorf("no value for key %s", tt.key.Inspect())
+			continue
+		}
+		if value.(*Integer).Value != tt.expected {
+			t.Errorf("wrong value for key %s. want=%d, got=%d",
+				tt.key.Inspect(), tt.expected, value.(*Integer).Value)
+		}
+	}
+
+	if _, ok := hash.Get(colliding); ok {
+		t.Errorf("found a value for a key that was never set")
+	}
+
+	hash.Set(b, &Integer{Value: 20})
+	if hash.Len() != 3 {
+		t.Errorf("replacing a colliding key added a pair. got=%d pairs", hash.Len())
+	}
+
+	expected := "{a: 1, b: 20, c: 3}"
+	if hash.Inspect() != expected {
+		t.Errorf("hash.Inspect() wrong. want=%q, got=%q", expected, hash.Inspect())
+	}
+}
+
+func TestHashKeysOfDifferentTypes(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&Integer{Value: 1}, &String{Value: "integer"})
+	hash.Set(&Boolean{Value: true}, &String{Value: "boolean"})
+	hash.Set(&String{Value: "1"}, &String{Value: "string"})
+
+	if hash.Len() != 3 {
+		t.Fatalf("hash has wrong length. want=3, got=%d", hash.Len())
+	}
+
+	value, _ := hash.Get(&Integer{Value: 1})
+	if value.Inspect() != "integer" {
+		t.Errorf("wrong value for 1. got=%s", value.Inspect())
+	}
+}
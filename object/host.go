@@ -0,0 +1,136 @@
+package object
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Host is what the embedding program lets a script reach outside the
+// interpreter. Every capability is off unless the host grants it: files
+// are accessible only under ReadPaths and WritePaths, and read_line, args
+// and env fail while Stdin, Args or Env are nil.
+type Host struct {
+	Stdout io.Writer // where puts writes; nil discards the output
+	Stdin  io.Reader // read by read_line
+
+	Args []string          // returned by args
+	Env  map[string]string // the variables env may see
+
+	ReadPaths  []string // directories and files read_file and list_dir may access
+	WritePaths []string // directories and files write_file may write to
+
+	stdin *bufio.Reader
+}
+
+// defaultHost is used by engines that were not given a Host. It keeps
+// the historical behaviour of puts printing to the process stdout.
+var defaultHost = &Host{Stdout: os.Stdout}
+
+// hostOf returns the Host of the engine running a builtin.
+func hostOf(rt Runtime) *Host {
+	if rt == nil || rt.Host() == nil {
+		return defaultHost
+	}
+	return rt.Host()
+}
+
+func (h *Host) stdout() io.Writer {
+	if h.Stdout == nil {
+		return io.Discard
+	}
+	return h.Stdout
+}
+
+// readLine reads a line from Stdin without its line ending. It returns
+// false at the end of the input.
+func (h *Host) readLine() (string, bool, error) {
+	if h.stdin == nil {
+		h.stdin = bufio.NewReader(h.Stdin)
+	}
+
+	line, err := h.stdin.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return "", false, nil
+	}
+	if err != nil && err != io.EOF {
+		return "", false, err
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, true, nil
+}
+
+// checkPath reports an error unless path lies within one of roots. It is
+// checked before touching the file system, so that scripts cannot probe
+// other paths, and again after following symbolic links, so that links
+// cannot lead out of the roots. The target of a write may not exist yet,
+// so only its directory has to, but it may not be a dangling link.
+func checkPath(name string, roots []string, path string, mayNotExist bool) (string, error) {
+	denied := fmt.Errorf("%s: access to %q is not permitted", name, path)
+
+	abs, err := filepath.Abs(path)
+	if err != nil || !withinAny(roots, abs, filepath.Abs) {
+		return "", denied
+	}
+
+	resolved, err := resolvePath(abs, mayNotExist)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", name, err)
+	}
+
+	resolveRoot := func(root string) (string, error) { return resolvePath(root, false) }
+	if !withinAny(roots, resolved, resolveRoot) {
+		return "", denied
+	}
+
+	return resolved, nil
+}
+
+func withinAny(roots []string, path string, resolve func(string) (string, error)) bool {
+	for _, root := range roots {
+		resolvedRoot, err := resolve(root)
+		if err == nil && within(resolvedRoot, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func resolvePath(path string, mayNotExist bool) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return resolved, nil
+	}
+	if !mayNotExist || !os.IsNotExist(err) {
+		return "", err
+	}
+	// A dangling symbolic link exists although its target does not.
+	// Writing to it would create the target, wherever it points.
+	if _, err := os.Lstat(abs); err == nil {
+		return "", fmt.Errorf("%s is a symbolic link to a missing file", abs)
+	}
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(abs)), nil
+}
+
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
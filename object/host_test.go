@@ -0,0 +1,192 @@
+package object
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hostRuntime runs builtins with a Host but cannot call functions.
+type hostRuntime struct {
+	host *Host
+}
+
+func (rt hostRuntime) Call(fn Object, args ...Object) Object {
+	return newError("not supported")
+}
+
+func (rt hostRuntime) Host() *Host { return rt.host }
+
+func callBuiltin(t *testing.T, host *Host, name string, args ...Object) Object {
+	t.Helper()
+	builtin := GetBuiltinByName(name)
+	if builtin == nil {
+		t.Fatalf("no builtin named %s", name)
+	}
+	return builtin.Fn(hostRuntime{host}, args...)
+}
+
+func str(s string) *String { return &String{Value: s} }
+
+func expectError(t *testing.T, obj Object, expected string) {
+	t.Helper()
+	errObj, ok := obj.(*Error)
+	if !ok {
+		t.Fatalf("expected error %q, got=%T (%+v)", expected, obj, obj)
+	}
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. want=%q, got=%q", expected, errObj.Message)
+	}
+}
+
+func TestFileBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	host := &Host{ReadPaths: []string{dir}, WritePaths: []string{dir}}
+
+	path := filepath.Join(dir, "greeting.txt")
+	result := callBuiltin(t, host, "write_file", str(path), str("hello"))
+	if result != NULL {
+		t.Fatalf("write_file returned %s", result.Inspect())
+	}
+
+	result = callBuiltin(t, host, "read_file", str(path))
+	if result.Inspect() != "hello" {
+		t.Errorf("read_file returned %q", result.Inspect())
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	result = callBuiltin(t, host, "list_dir", str(dir))
+	if result.Inspect() != "[greeting.txt, sub]" {
+		t.Errorf("list_dir returned %s", result.Inspect())
+	}
+}
+
+func TestFileBuiltinsDenied(t *testing.T) {
+	root := t.TempDir()
+	allowed := filepath.Join(root, "allowed")
+	if err := os.Mkdir(allowed, 0755); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(root, "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(allowed, "link.txt")
+	if err := os.Symlink(secret, link); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := filepath.Join(root, "missing.txt")
+	dangling := filepath.Join(allowed, "dangling.txt")
+	if err := os.Symlink(missing, dangling); err != nil {
+		t.Fatal(err)
+	}
+
+	host := &Host{ReadPaths: []string{allowed}, WritePaths: []string{allowed}}
+	escape := filepath.Join(allowed, "..", "secret.txt")
+
+	tests := []struct {
+		host     *Host
+		name     string
+		args     []Object
+		expected string
+	}{
+		{&Host{}, "read_file", []Object{str(secret)},
+			`read_file: access to "` + secret + `" is not permitted`},
+		{nil, "read_file", []Object{str(secret)},
+			`read_file: access to "` + secret + `" is not permitted`},
+		{host, "read_file", []Object{str(escape)},
+			`read_file: access to "` + escape + `" is not permitted`},
+		{host, "read_file", []Object{str(link)},
+			`read_file: access to "` + link + `" is not permitted`},
+		{host, "list_dir", []Object{str(root)},
+			`list_dir: access to "` + root + `" is not permitted`},
+		{host, "write_file", []Object{str(link), str("x")},
+			`write_file: access to "` + link + `" is not permitted`},
+		{host, "write_file", []Object{str(dangling), str("x")},
+			`write_file: ` + dangling + ` is a symbolic link to a missing file`},
+		{host, "write_file", []Object{str(secret), str("x")},
+			`write_file: access to "` + secret + `" is not permitted`},
+		{&Host{ReadPaths: []string{allowed}}, "write_file",
+			[]Object{str(filepath.Join(allowed, "new.txt")), str("x")},
+			`write_file: access to "` + filepath.Join(allowed, "new.txt") + `" is not permitted`},
+		{host, "read_file", []Object{&Integer{Value: 1}},
+			"argument to `read_file` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		expectError(t, callBuiltin(t, tt.host, tt.name, tt.args...), tt.expected)
+	}
+
+	content, err := os.ReadFile(secret)
+	if err != nil || string(content) != "secret" {
+		t.Errorf("secret was modified: %q, %v", content, err)
+	}
+	if _, err := os.Lstat(missing); !os.IsNotExist(err) {
+		t.Errorf("write_file created %s through a dangling link", missing)
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	host := &Host{Stdin: strings.NewReader("first\r\nsecond\nlast")}
+
+	for _, expected := range []string{"first", "second", "last"} {
+		result := callBuiltin(t, host, "read_line")
+		if result.Inspect() != expected {
+			t.Errorf("read_line returned %q, want=%q", result.Inspect(), expected)
+		}
+	}
+	if result := callBuiltin(t, host, "read_line"); result != NULL {
+		t.Errorf("read_line at end of input returned %s", result.Inspect())
+	}
+
+	expectError(t, callBuiltin(t, &Host{}, "read_line"),
+		"read_line: reading input is not permitted")
+}
+
+func TestArgsAndEnv(t *testing.T) {
+	host := &Host{
+		Args: []string{"a", "b"},
+		Env:  map[string]string{"USER": "monkey", "HOME": "/home/monkey"},
+	}
+
+	tests := []struct {
+		name     string
+		args     []Object
+		expected string
+	}{
+		{"args", nil, "[a, b]"},
+		{"env", nil, "{HOME: /home/monkey, USER: monkey}"},
+		{"env", []Object{str("USER")}, "monkey"},
+		{"env", []Object{str("PATH")}, "null"},
+	}
+
+	for _, tt := range tests {
+		result := callBuiltin(t, host, tt.name, tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s returned %s, want=%s", tt.name, result.Inspect(), tt.expected)
+		}
+	}
+
+	expectError(t, callBuiltin(t, &Host{}, "args"),
+		"args: reading arguments is not permitted")
+	expectError(t, callBuiltin(t, &Host{}, "env", str("USER")),
+		"env: reading the environment is not permitted")
+}
+
+func TestPutsWritesToHost(t *testing.T) {
+	var out bytes.Buffer
+	host := &Host{Stdout: &out}
+
+	callBuiltin(t, host, "puts", str("hello"), &Integer{Value: 1})
+	if out.String() != "hello\n1\n" {
+		t.Errorf("puts wrote %q", out.String())
+	}
+
+	// Without a Stdout the output is discarded.
+	callBuiltin(t, &Host{}, "puts", str("hello"))
+}
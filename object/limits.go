@@ -0,0 +1,194 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrStepLimitExceeded is returned when a program takes more steps
+	// than its budget allows.
+	ErrStepLimitExceeded = errors.New("step limit exceeded")
+	// ErrAllocationLimitExceeded is returned when a program allocates more
+	// bytes in total than its budget allows.
+	ErrAllocationLimitExceeded = errors.New("allocation limit exceeded")
+	// ErrCanceled is returned when the context of a program is done. The
+	// error also wraps the context's error.
+	ErrCanceled = errors.New("execution canceled")
+)
+
+// contextCheckInterval is the number of steps between checks of the
+// context, which are much more expensive than counting a step.
+const contextCheckInterval = 1024
+
+// Budget is how much a program may do. Zero values mean no limit.
+type Budget struct {
+	// MaxSteps is the number of steps a program may take. What a step is
+	// depends on the engine: the VM counts instructions, the evaluator
+	// counts statements and function calls.
+	MaxSteps int64
+	// MaxAllocated is the number of bytes of arrays, strings and hashes a
+	// program may allocate in total, as estimated by SizeOf. Values that
+	// become garbage are not given back, so this limits the work a
+	// program does rather than the memory it holds at any time. To only
+	// measure allocations, set it to math.MaxInt64.
+	MaxAllocated int64
+}
+
+// Limits stops a running program once it exceeds its Budget or its
+// context is done.
+type Limits struct {
+	ctx       context.Context
+	budget    Budget
+	steps     int64
+	allocated int64
+	err       error
+}
+
+// NewLimits returns Limits for ctx and budget, or nil if ctx can never be
+// canceled and the budget has no limits, so that engines can skip counting
+// altogether.
+func NewLimits(ctx context.Context, budget Budget) *Limits {
+	if ctx.Done() == nil && budget.MaxSteps <= 0 && budget.MaxAllocated <= 0 {
+		return nil
+	}
+	return &Limits{ctx: ctx, budget: budget}
+}
+
+// Step counts one step. Once a limit is reached it returns the same error
+// on every call.
+func (l *Limits) Step() error {
+	if l.err != nil {
+		return l.err
+	}
+
+	l.steps++
+	if l.budget.MaxSteps > 0 && l.steps > l.budget.MaxSteps {
+		l.err = ErrStepLimitExceeded
+	} else if l.steps%contextCheckInterval == 0 {
+		l.checkContext()
+	}
+
+	return l.err
+}
+
+// Alloc counts the allocation of obj. Once the allocation limit is reached
+// it returns the same error on every call.
+func (l *Limits) Alloc(obj Object) error {
+	if l.err != nil {
+		return l.err
+	}
+
+	l.allocated += SizeOf(obj)
+	if l.budget.MaxAllocated > 0 && l.allocated > l.budget.MaxAllocated {
+		l.err = ErrAllocationLimitExceeded
+	}
+
+	return l.err
+}
+
+// Reserve reports ErrAllocationLimitExceeded if allocating size more bytes
+// would exceed the allocation limit, without counting them. Builtins call it
+// before building values that can be much larger than their arguments.
+func (l *Limits) Reserve(size int64) error {
+	if l.err == nil && l.budget.MaxAllocated > 0 && size > l.budget.MaxAllocated-l.allocated {
+		l.err = ErrAllocationLimitExceeded
+	}
+	return l.err
+}
+
+// Check reports whether the context is already done, without counting a
+// step.
+func (l *Limits) Check() error {
+	if l.err == nil {
+		l.checkContext()
+	}
+	return l.err
+}
+
+func (l *Limits) checkContext() {
+	if err := l.ctx.Err(); err != nil {
+		l.err = fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+}
+
+// Err returns the error of the limit that was reached, if any.
+func (l *Limits) Err() error {
+	return l.err
+}
+
+// Steps returns the number of steps taken so far.
+func (l *Limits) Steps() int64 {
+	return l.steps
+}
+
+// Allocated returns the number of bytes allocated so far. The engines do
+// not track when values become garbage, so this is the total of all
+// allocations, not the memory in use.
+func (l *Limits) Allocated() int64 {
+	return l.allocated
+}
+
+// Estimated sizes in bytes of the parts of values, for a 64-bit platform.
+const (
+	headerSize = 16 // a string header, or an interface holding a pointer
+	sliceSize  = 24
+	pairSize   = 2*headerSize + 32 // key, value and their entry in the hash
+)
+
+// SizeOf estimates the memory obj itself takes up, not counting the values
+// it refers to, which are counted when they are allocated. Only arrays,
+// strings and hashes are counted; other values are small and fixed-size.
+func SizeOf(obj Object) int64 {
+	switch obj := obj.(type) {
+	case *String:
+		return headerSize + int64(len(obj.Value))
+	case *Array:
+		return sliceSize + headerSize*int64(len(obj.Elements))
+	case *Hash:
+		return sliceSize + pairSize*int64(obj.Len())
+	default:
+		return 0
+	}
+}
+
+// limitedRuntime is implemented by runtimes that can run under Limits.
+type limitedRuntime interface {
+	Limits() *Limits
+}
+
+// alloc counts obj, a value a builtin just created, against the
+// allocation limit of its runtime. Builtins call it only for fresh values, so that
+// returning an argument or one of its elements allocates nothing.
+func alloc(rt Runtime, obj Object) Object {
+	limited, ok := rt.(limitedRuntime)
+	if !ok || isError(obj) {
+		return obj
+	}
+	limits := limited.Limits()
+	if limits == nil {
+		return obj
+	}
+	if err := limits.Alloc(obj); err != nil {
+		return newError("%s", err)
+	}
+	return obj
+}
+
+// reserve checks with Limits.Reserve that the runtime of a builtin may
+// allocate size bytes.
+func reserve(rt Runtime, size int64) *Error {
+	limited, ok := rt.(limitedRuntime)
+	if !ok {
+		return nil
+	}
+	limits := limited.Limits()
+	if limits == nil {
+		return nil
+	}
+	if err := limits.Reserve(size); err != nil {
+		return newError("%s", err)
+	}
+	return nil
+}
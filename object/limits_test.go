@@ -0,0 +1,84 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNewLimitsWithoutLimits(t *testing.T) {
+	if limits := NewLimits(context.Background(), Budget{}); limits != nil {
+		t.Errorf("expected nil Limits without limits, got=%+v", limits)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if limits := NewLimits(ctx, Budget{}); limits == nil {
+		t.Errorf("expected Limits for a cancelable context")
+	}
+}
+
+func TestLimitsAllocation(t *testing.T) {
+	limits := NewLimits(context.Background(), Budget{MaxAllocated: 100})
+
+	if err := limits.Alloc(&String{Value: "hello"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if limits.Allocated() != headerSize+5 {
+		t.Errorf("wrong allocation. got=%d", limits.Allocated())
+	}
+
+	// Reserve does not count the bytes.
+	if err := limits.Reserve(100 - limits.Allocated()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := limits.Reserve(math.MaxInt64); !errors.Is(err, ErrAllocationLimitExceeded) {
+		t.Errorf("expected allocation limit error, got=%v", err)
+	}
+	if err := limits.Step(); !errors.Is(err, ErrAllocationLimitExceeded) {
+		t.Errorf("expected the error to stay, got=%v", err)
+	}
+}
+
+func TestSizeOf(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&String{Value: "a"}, &Integer{Value: 1})
+
+	tests := []struct {
+		obj      Object
+		expected int64
+	}{
+		{&Integer{Value: 1}, 0},
+		{&String{Value: "abc"}, 19},
+		{&Array{Elements: []Object{NULL, NULL}}, 56},
+		{hash, 88},
+	}
+
+	for _, tt := range tests {
+		if size := SizeOf(tt.obj); size != tt.expected {
+			t.Errorf("SizeOf(%s) wrong. want=%d, got=%d", tt.obj.Inspect(), tt.expected, size)
+		}
+	}
+}
+
+func TestRangeLength(t *testing.T) {
+	tests := []struct {
+		start, end, step int64
+		expected         int64
+	}{
+		{0, 10, 1, 10},
+		{0, 10, 3, 4},
+		{10, 0, -2, 5},
+		{5, 5, 1, 0},
+		{0, 10, -1, 0},
+		{math.MinInt64, math.MaxInt64, 1, math.MaxInt64 / headerSize},
+	}
+
+	for _, tt := range tests {
+		if n := rangeLength(tt.start, tt.end, tt.step); n != tt.expected {
+			t.Errorf("rangeLength(%d, %d, %d) wrong. want=%d, got=%d",
+				tt.start, tt.end, tt.step, tt.expected, n)
+		}
+	}
+}
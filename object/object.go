@@ -2,13 +2,28 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
 	"monkey/ast"
+	"monkey/code"
 	"strings"
+	"unicode/utf8"
 )
 
-type BuiltinFunction func(args ...Object) Object
+// Runtime is the engine running a builtin. Builtins use it to call back
+// into Monkey functions, so higher-order builtins such as map behave the same
+// in the evaluator and the VM.
+type Runtime interface {
+	// Call applies fn, a Monkey function or builtin, to args. Failures are
+	// returned as *Error.
+	Call(fn Object, args ...Object) Object
+	// Host returns what the embedding program grants the script, or nil
+	// for the default, which only lets puts print to os.Stdout.
+	Host() *Host
+}
+
+type BuiltinFunction func(rt Runtime, args ...Object) Object
 
 type ObjectType string
 
@@ -22,21 +37,22 @@ const (
 
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 
-	FUNCTION_OBJ = "FUNCTION"
-	BUILTIN_OBJ  = "BUILTIN"
+	FUNCTION_OBJ          = "FUNCTION"
+	BUILTIN_OBJ           = "BUILTIN"
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CLOSURE_OBJ           = "CLOSURE"
 
 	ARRAY_OBJ = "ARRAY"
 	HASH_OBJ  = "HASH"
 )
 
-type HashKey struct {
-	Type  ObjectType
-	Value uint64
-}
-
-type Hashable interface {
-	HashKey() HashKey
-}
+// The singleton values shared by both engines, so that they can be compared
+// by identity.
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
 
 type Object interface {
 	Type() ObjectType
@@ -85,12 +101,16 @@ func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
 type Error struct {
 	Message string
+	// Stack lists the functions that were being called, innermost first,
+	// for errors that report where they happened.
+	Stack []string
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
 type Function struct {
+	Name       string // the name it was bound to with let, if any
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -115,12 +135,40 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure is a compiled function together with the free variables it
+// captured when it was created.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
 type String struct {
 	Value string
 }
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
+
+// Len returns the length of the string in runes rather than bytes, so that
+// non-ASCII text is measured by its characters.
+func (s *String) Len() int { return utf8.RuneCountInString(s.Value) }
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -140,43 +188,56 @@ type Array struct {
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
-func (ao *Array) Inspect() string {
-	var out bytes.Buffer
 
-	elements := []string{}
-	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
-	}
+// HashKey combines the hash keys of the elements, so arrays with equal
+// elements share a key. Elements that are not hashable only contribute
+// their type; Hash tells such keys apart with Equals.
+func (ao *Array) HashKey() HashKey {
+	return HashKey{Type: ao.Type(), Value: ao.hashValue(nil)}
+}
 
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
+func (ao *Array) hashValue(visiting map[*Array]bool) uint64 {
+	if visiting[ao] {
+		return 0
+	}
+	if visiting == nil {
+		visiting = make(map[*Array]bool)
+	}
+	visiting[ao] = true
+	defer delete(visiting, ao)
 
-	return out.String()
-}
+	h := fnv.New64a()
+	var buf [8]byte
 
-type HashPair struct {
-	Key   Object
-	Value Object
-}
+	for _, e := range ao.Elements {
+		var value uint64
+
+		switch e := e.(type) {
+		case *Array:
+			value = e.hashValue(visiting)
+		case Hashable:
+			value = e.HashKey().Value
+		}
+
+		h.Write([]byte(e.Type()))
+		binary.BigEndian.PutUint64(buf[:], value)
+		h.Write(buf[:])
+	}
 
-type Hash struct {
-	Pairs map[HashKey]HashPair
+	return h.Sum64()
 }
 
-func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
+func (ao *Array) Inspect() string {
 	var out bytes.Buffer
 
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+	elements := []string{}
+	for _, e := range ao.Elements {
+		elements = append(elements, e.Inspect())
 	}
 
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
 
 	return out.String()
 }
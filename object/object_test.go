@@ -58,3 +58,103 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestStringLen(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"", 0},
+		{"monkey", 6},
+		{"한글", 2},
+		{"naïve 😀", 7},
+	}
+
+	for _, tt := range tests {
+		str := &String{Value: tt.input}
+		if str.Len() != tt.expected {
+			t.Errorf("wrong length for %q. want=%d, got=%d",
+				tt.input, tt.expected, str.Len())
+		}
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	array1 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "two"}}}
+	array2 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "two"}}}
+	diff := &Array{Elements: []Object{&String{Value: "two"}, &Integer{Value: 1}}}
+	nested1 := &Array{Elements: []Object{array1}}
+	nested2 := &Array{Elements: []Object{array2}}
+
+	if array1.HashKey() != array2.HashKey() {
+		t.Errorf("arrays with same content have different hash keys")
+	}
+
+	if nested1.HashKey() != nested2.HashKey() {
+		t.Errorf("nested arrays with same content have different hash keys")
+	}
+
+	if array1.HashKey() == diff.HashKey() {
+		t.Errorf("arrays with different content have same hash keys")
+	}
+
+	cyclic := &Array{}
+	cyclic.Elements = []Object{&Integer{Value: 1}, cyclic}
+	cyclic.HashKey()
+}
+
+func TestEquals(t *testing.T) {
+	one := &Integer{Value: 1}
+	hash1 := NewHash()
+	hash1.Set(&String{Value: "a"}, one)
+	hash1.Set(&String{Value: "b"}, &Array{Elements: []Object{one}})
+	hash2 := NewHash()
+	hash2.Set(&String{Value: "b"}, &Array{Elements: []Object{&Integer{Value: 1}}})
+	hash2.Set(&String{Value: "a"}, &Integer{Value: 1})
+	hash3 := NewHash()
+	hash3.Set(&String{Value: "a"}, &Integer{Value: 2})
+	hash3.Set(&String{Value: "b"}, &Array{Elements: []Object{one}})
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{one, &Integer{Value: 1}, true},
+		{one, &Integer{Value: 2}, false},
+		{one, &String{Value: "1"}, false},
+		{&String{Value: "한글"}, &String{Value: "한글"}, true},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&Null{}, &Null{}, true},
+		{&Null{}, &Boolean{Value: false}, false},
+		{&Array{}, &Array{}, true},
+		{&Array{Elements: []Object{one}}, &Array{Elements: []Object{&Integer{Value: 1}}}, true},
+		{&Array{Elements: []Object{one}}, &Array{Elements: []Object{one, one}}, false},
+		{hash1, hash2, true},
+		{hash1, hash3, false},
+		{&Function{}, &Function{}, false},
+	}
+
+	for i, tt := range tests {
+		if Equals(tt.a, tt.b) != tt.expected {
+			t.Errorf("tests[%d] - Equals(%s, %s) wrong. want=%t",
+				i, tt.a.Inspect(), tt.b.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestEqualsCyclicValues(t *testing.T) {
+	a := &Array{}
+	a.Elements = []Object{&Integer{Value: 1}, a}
+	b := &Array{}
+	b.Elements = []Object{&Integer{Value: 1}, b}
+	c := &Array{}
+	c.Elements = []Object{&Integer{Value: 2}, c}
+
+	if !Equals(a, b) {
+		t.Errorf("equal cyclic arrays compared unequal")
+	}
+
+	if Equals(a, c) {
+		t.Errorf("different cyclic arrays compared equal")
+	}
+}
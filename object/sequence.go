@@ -0,0 +1,138 @@
+package object
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Arrays and strings are indexed and sliced the same way in both engines:
+// indexes are zero-based, negative indexes count back from the end, and
+// strings are indexed by rune.
+
+// normalizeIndex resolves i against a sequence of the given length,
+// reporting false if it is out of range.
+func normalizeIndex(i int64, length int) (int, bool) {
+	if i < 0 {
+		i += int64(length)
+	}
+	if i < 0 || i >= int64(length) {
+		return 0, false
+	}
+	return int(i), true
+}
+
+// At returns the element at index i.
+func (ao *Array) At(i int64) (Object, bool) {
+	idx, ok := normalizeIndex(i, len(ao.Elements))
+	if !ok {
+		return nil, false
+	}
+	return ao.Elements[idx], true
+}
+
+// At returns the rune at index i as a one-character string. It decodes
+// only the runes up to i, from the end for negative indexes, so indexing
+// neither converts nor measures the whole string.
+func (s *String) At(i int64) (*String, bool) {
+	var offset int
+	if i >= 0 {
+		offset = skipRunes(s.Value, 0, i)
+		if offset == len(s.Value) {
+			return nil, false
+		}
+	} else {
+		offset = len(s.Value)
+		for ; i < 0; i++ {
+			if offset == 0 {
+				return nil, false
+			}
+			_, size := utf8.DecodeLastRuneInString(s.Value[:offset])
+			offset -= size
+		}
+	}
+
+	r, _ := utf8.DecodeRuneInString(s.Value[offset:])
+	return &String{Value: string(r)}, true
+}
+
+// skipRunes returns the byte offset n runes after offset in s, or len(s)
+// if s ends before.
+func skipRunes(s string, offset int, n int64) int {
+	for ; n > 0 && offset < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// Slice returns the elements of an array, or the runes of a string, from
+// start up to but not including end. Either bound may be null to mean the
+// start or the end of the sequence. Bounds are clamped to the sequence,
+// as in Python, so slicing never fails because of them.
+func Slice(left, start, end Object) (Object, error) {
+	switch left := left.(type) {
+	case *Array:
+		from, to, err := sliceBounds(start, end, len(left.Elements))
+		if err != nil {
+			return nil, err
+		}
+
+		elements := make([]Object, to-from)
+		copy(elements, left.Elements[from:to])
+		return &Array{Elements: elements}, nil
+	case *String:
+		from, to, err := sliceBounds(start, end, left.Len())
+		if err != nil {
+			return nil, err
+		}
+
+		begin := skipRunes(left.Value, 0, int64(from))
+		value := left.Value[begin:skipRunes(left.Value, begin, int64(to-from))]
+		// Invalid bytes become U+FFFD, as they do when indexing.
+		if !utf8.ValidString(value) {
+			value = string([]rune(value))
+		}
+		return &String{Value: value}, nil
+	default:
+		return nil, fmt.Errorf("slice operator not supported: %s", left.Type())
+	}
+}
+
+func sliceBounds(start, end Object, length int) (int, int, error) {
+	from, err := sliceBound(start, 0, length)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	to, err := sliceBound(end, length, length)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if to < from {
+		to = from
+	}
+
+	return from, to, nil
+}
+
+func sliceBound(bound Object, omitted, length int) (int, error) {
+	switch bound := bound.(type) {
+	case *Null:
+		return omitted, nil
+	case *Integer:
+		i := bound.Value
+		if i < 0 {
+			i += int64(length)
+		}
+		if i < 0 {
+			return 0, nil
+		}
+		if i > int64(length) {
+			return length, nil
+		}
+		return int(i), nil
+	default:
+		return 0, fmt.Errorf("slice bound must be INTEGER, got %s", bound.Type())
+	}
+}
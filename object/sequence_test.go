@@ -0,0 +1,76 @@
+package object
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStringAt(t *testing.T) {
+	tests := []struct {
+		value    string
+		index    int64
+		expected string
+		ok       bool
+	}{
+		{"héllo", 0, "h", true},
+		{"héllo", 1, "é", true},
+		{"héllo", 4, "o", true},
+		{"héllo", 5, "", false},
+		{"héllo", -1, "o", true},
+		{"héllo", -4, "é", true},
+		{"héllo", -5, "h", true},
+		{"héllo", -6, "", false},
+		{"", 0, "", false},
+		{"", -1, "", false},
+		{"a\xffb", 1, "�", true},
+	}
+
+	for _, tt := range tests {
+		char, ok := (&String{Value: tt.value}).At(tt.index)
+		if ok != tt.ok {
+			t.Errorf("%q[%d]: wrong ok. want=%t, got=%t", tt.value, tt.index, tt.ok, ok)
+			continue
+		}
+		if ok && char.Value != tt.expected {
+			t.Errorf("%q[%d]: wrong char. want=%q, got=%q", tt.value, tt.index, tt.expected, char.Value)
+		}
+	}
+}
+
+func TestStringSlice(t *testing.T) {
+	tests := []struct {
+		value      string
+		start, end Object
+		expected   string
+	}{
+		{"héllo", &Integer{Value: 1}, &Integer{Value: 3}, "él"},
+		{"héllo", &Integer{Value: -2}, NULL, "lo"},
+		{"héllo", NULL, &Integer{Value: 100}, "héllo"},
+		{"héllo", &Integer{Value: 3}, &Integer{Value: 1}, ""},
+		{"a\xffb", &Integer{Value: 1}, NULL, "�b"},
+	}
+
+	for _, tt := range tests {
+		result, err := Slice(&String{Value: tt.value}, tt.start, tt.end)
+		if err != nil {
+			t.Fatalf("%q: slice error: %s", tt.value, err)
+		}
+		if result.(*String).Value != tt.expected {
+			t.Errorf("%q[%s:%s]: want=%q, got=%q", tt.value, tt.start.Inspect(), tt.end.Inspect(), tt.expected, result.(*String).Value)
+		}
+	}
+}
+
+// Indexing a long string must not copy it, or indexing every character
+// would take quadratic time and memory.
+func TestStringAtDoesNotCopy(t *testing.T) {
+	s := &String{Value: strings.Repeat("é", 1<<16)}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		s.At(3)
+		s.At(-3)
+	})
+	if allocs > 4 {
+		t.Errorf("indexing allocated %.0f times", allocs)
+	}
+}
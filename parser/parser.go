@@ -6,11 +6,13 @@ import (
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	COALESCE    // ??
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +
@@ -21,6 +23,7 @@ const (
 )
 
 var precedences = map[token.TokenType]int{
+	token.COALESCE: COALESCE,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
@@ -31,6 +34,8 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+
+	token.OPT_LBRACKET: INDEX,
 }
 
 type (
@@ -59,15 +64,18 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.INTERP_HEAD, p.parseInterpolatedString)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNull)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -78,9 +86,11 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.COALESCE, p.parseInfixExpression)
 
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.OPT_LBRACKET, p.parseIndexExpression)
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -170,6 +180,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -248,9 +262,10 @@ func (p *Parser) parseIdentifier() ast.Expression {
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	value, err := parseInteger(p.curToken.Literal)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
+		msg := fmt.Sprintf("could not parse %q as integer: %s",
+			p.curToken.Literal, err)
 		p.errors = append(p.errors, msg)
 		return nil
 	}
@@ -260,10 +275,102 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+var integerBases = []struct {
+	prefix string
+	base   int
+	name   string
+}{
+	{"0x", 16, "hexadecimal"},
+	{"0o", 8, "octal"},
+	{"0b", 2, "binary"},
+}
+
+// parseInteger parses decimal, 0x hexadecimal, 0o octal and 0b binary
+// integer literals, any of which may separate digits with underscores.
+func parseInteger(literal string) (int64, error) {
+	digits, base, name := literal, 10, "decimal"
+	for _, b := range integerBases {
+		if len(literal) >= 2 && strings.EqualFold(literal[:2], b.prefix) {
+			digits, base, name = literal[2:], b.base, b.name
+			break
+		}
+	}
+
+	for _, ch := range digits {
+		if ch != '_' && !isDigitInBase(ch, base) {
+			return 0, fmt.Errorf("invalid digit %q in %s literal", ch, name)
+		}
+	}
+
+	// a single underscore may follow the base prefix, as in 0x_ff
+	stripped := strings.ReplaceAll(digits, "_", "")
+	if stripped == "" {
+		return 0, fmt.Errorf("%s literal has no digits", name)
+	}
+	if strings.HasSuffix(digits, "_") || strings.Contains(digits, "__") {
+		return 0, fmt.Errorf("'_' must separate successive digits")
+	}
+
+	value, err := strconv.ParseInt(stripped, base, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s literal out of range", name)
+	}
+
+	return value, nil
+}
+
+func isDigitInBase(ch rune, base int) bool {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch-'0') < base
+	case 'a' <= ch && ch <= 'f':
+		return base == 16
+	case 'A' <= ch && ch <= 'F':
+		return base == 16
+	default:
+		return false
+	}
+}
+
+func (p *Parser) parseIllegal() ast.Expression {
+	msg := fmt.Sprintf("illegal token at line %d, column %d: %s",
+		p.curToken.Line, p.curToken.Column, p.curToken.Literal)
+	p.errors = append(p.errors, msg)
+	return nil
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	str.Parts = p.appendStringPart(str.Parts)
+
+	for !p.curTokenIs(token.INTERP_TAIL) {
+		p.nextToken()
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.INTERP_MIDDLE) && !p.peekTokenIs(token.INTERP_TAIL) {
+			p.peekError(token.INTERP_TAIL)
+			return nil
+		}
+		p.nextToken()
+		str.Parts = p.appendStringPart(str.Parts)
+	}
+
+	return str
+}
+
+// appendStringPart adds the text of the current INTERP_* token to parts,
+// leaving out empty text between adjacent expressions.
+func (p *Parser) appendStringPart(parts []ast.Expression) []ast.Expression {
+	if p.curToken.Literal == "" {
+		return parts
+	}
+	return append(parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -295,6 +402,10 @@ func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseNull() ast.Expression {
+	return &ast.Null{Token: p.curToken}
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -371,6 +482,7 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 
 	lit.Body = p.parseBlockStatement()
+	ast.MarkTailCalls(lit)
 
 	return lit
 }
@@ -441,10 +553,40 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
+	optional := p.curTokenIs(token.OPT_LBRACKET)
 
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+
+	var index ast.Expression
+	if !p.curTokenIs(token.COLON) {
+		index = p.parseExpression(LOWEST)
+
+		if !p.peekTokenIs(token.COLON) {
+			if !p.expectPeek(token.RBRACKET) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: tok, Left: left, Index: index, Optional: optional}
+		}
+		p.nextToken()
+	}
+
+	return p.parseSliceExpression(tok, left, index, optional)
+}
+
+// parseSliceExpression parses the rest of left[start:end] with the current
+// token on the colon.
+func (p *Parser) parseSliceExpression(
+	tok token.Token,
+	left, start ast.Expression,
+	optional bool,
+) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start, Optional: optional}
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
@@ -469,6 +611,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -139,6 +139,73 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0", 0},
+		{"007", 7},
+		{"0xff", 255},
+		{"0XFF", 255},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0xdead_beef", 0xdeadbeef},
+		{"0b_1010", 10},
+		{"9223372036854775807", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value for %q not %d. got=%d",
+				tt.input, tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestMalformedIntegerLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0x", `could not parse "0x" as integer: hexadecimal literal has no digits`},
+		{"0xfg", `could not parse "0xfg" as integer: invalid digit 'g' in hexadecimal literal`},
+		{"0o18", `could not parse "0o18" as integer: invalid digit '8' in octal literal`},
+		{"0b102", `could not parse "0b102" as integer: invalid digit '2' in binary literal`},
+		{"12abc", `could not parse "12abc" as integer: invalid digit 'a' in decimal literal`},
+		{"1__000", `could not parse "1__000" as integer: '_' must separate successive digits`},
+		{"1000_", `could not parse "1000_" as integer: '_' must separate successive digits`},
+		{"9223372036854775808", `could not parse "9223372036854775808" as integer: decimal literal out of range`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expected, errors[0])
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -349,6 +416,22 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a ?? b == c",
+			"(a ?? (b == c))",
+		},
+		{
+			"a ?? b ?? c",
+			"((a ?? b) ?? c)",
+		},
+		{
+			"h?[\"a\"]?[\"b\"] ?? 1 + 2",
+			"(((h?[a])?[b]) ?? (1 + 2))",
+		},
+		{
+			"!null",
+			"(!null)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -401,6 +484,44 @@ func TestBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestNullLiteral(t *testing.T) {
+	l := lexer.New("null;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	null, ok := stmt.Expression.(*ast.Null)
+	if !ok {
+		t.Fatalf("exp not *ast.Null. got=%T", stmt.Expression)
+	}
+	if null.TokenLiteral() != "null" {
+		t.Errorf("null.TokenLiteral not %q. got=%q", "null", null.TokenLiteral())
+	}
+}
+
+func TestParsingOptionalIndexExpressions(t *testing.T) {
+	l := lexer.New("myArray?[1 + 1]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+	if !indexExp.Optional {
+		t.Errorf("indexExp.Optional is not true")
+	}
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
 func TestIfExpression(t *testing.T) {
 	input := `if (x < y) { x }`
 
@@ -556,6 +677,37 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T",
+			program.Statements[0])
+	}
+
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T",
+			stmt.Value)
+	}
+
+	if function.Name != "myFunction" {
+		t.Fatalf("function literal name wrong. want 'myFunction', got=%q\n",
+			function.Name)
+	}
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -697,6 +849,84 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestIllegalTokenErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = "abc`, "illegal token at line 1, column 9: unterminated string"},
+		{"let a = 1;\nlet b = \"\\q\";", `illegal token at line 2, column 9: invalid escape sequence: \q`},
+		{"1 + @", "illegal token at line 1, column 5: @"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expected, errors[0])
+		}
+	}
+}
+
+func TestInterpolatedStringParsing(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedParts []interface{}
+		expected      string
+	}{
+		{`"total: ${a + b}!"`, []interface{}{"total: ", "(a + b)", "!"}, "total: ${(a + b)}!"},
+		{`"${x}${y}"`, []interface{}{"x", "y"}, "${x}${y}"},
+		{`"a ${ "b ${c}" } d"`, []interface{}{"a ", "b ${c}", " d"}, "a ${b ${c}} d"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		str, ok := stmt.Expression.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+		}
+
+		if len(str.Parts) != len(tt.expectedParts) {
+			t.Fatalf("wrong number of parts. want=%d, got=%d",
+				len(tt.expectedParts), len(str.Parts))
+		}
+
+		for i, part := range str.Parts {
+			if part.String() != tt.expectedParts[i] {
+				t.Errorf("parts[%d] wrong. want=%q, got=%q",
+					i, tt.expectedParts[i], part.String())
+			}
+		}
+
+		if str.String() != tt.expected {
+			t.Errorf("str.String() wrong. want=%q, got=%q", tt.expected, str.String())
+		}
+	}
+}
+
+func TestUnterminatedInterpolation(t *testing.T) {
+	l := lexer.New(`"a ${b"`)
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors, got none")
+	}
+}
+
 func TestParsingEmptyArrayLiterals(t *testing.T) {
 	input := "[]"
 
@@ -762,6 +992,53 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"arr[1:2]", "(arr[1:2])"},
+		{"arr[:2]", "(arr[:2])"},
+		{"arr[1:]", "(arr[1:])"},
+		{"arr[:]", "(arr[:])"},
+		{"arr[-1:len(arr) - 1]", "(arr[(-1):(len(arr) - 1)])"},
+		{"arr?[1:2]", "(arr?[1:2])"},
+		{"arr[1:2][0]", "((arr[1:2])[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+
+	l := lexer.New("arr[1:2]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	slice, ok := stmt.Expression.(*ast.SliceExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, slice.Left, "arr") {
+		return
+	}
+	if !testIntegerLiteral(t, slice.Start, 1) {
+		return
+	}
+	if !testIntegerLiteral(t, slice.End, 2) {
+		return
+	}
+}
+
 func TestParsingEmptyHashLiteral(t *testing.T) {
 	input := "{}"
 
@@ -1069,6 +1346,83 @@ func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
 	return true
 }
 
+func TestTailCallMarking(t *testing.T) {
+	input := `
+	fn(x) {
+		a(x);
+		let y = b(x);
+		if (x) { return c(x); }
+		if (y) { d(x) } else { fn() { e(x) }; f(x) + g(x) }
+	}`
+	expected := map[string]bool{
+		"a": false, "b": false, "c": true, "d": true, "e": true, "f": false, "g": false,
+	}
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	calls := map[string]bool{}
+	collectCalls(program, calls)
+	for name, tail := range expected {
+		got, ok := calls[name]
+		if !ok {
+			t.Errorf("call of %s not found", name)
+			continue
+		}
+		if got != tail {
+			t.Errorf("call of %s has wrong Tail. want=%t, got=%t", name, tail, got)
+		}
+	}
+
+	// Calls outside of functions are never tail calls.
+	p = New(lexer.New("h(1)"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	calls = map[string]bool{}
+	collectCalls(program, calls)
+	if calls["h"] {
+		t.Errorf("top-level call marked as tail call")
+	}
+}
+
+// collectCalls records whether the calls of named functions in node are
+// marked as tail calls.
+func collectCalls(node ast.Node, calls map[string]bool) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			collectCalls(s, calls)
+		}
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			collectCalls(s, calls)
+		}
+	case *ast.ExpressionStatement:
+		collectCalls(node.Expression, calls)
+	case *ast.LetStatement:
+		collectCalls(node.Value, calls)
+	case *ast.ReturnStatement:
+		collectCalls(node.ReturnValue, calls)
+	case *ast.InfixExpression:
+		collectCalls(node.Left, calls)
+		collectCalls(node.Right, calls)
+	case *ast.IfExpression:
+		collectCalls(node.Consequence, calls)
+		if node.Alternative != nil {
+			collectCalls(node.Alternative, calls)
+		}
+	case *ast.FunctionLiteral:
+		collectCalls(node.Body, calls)
+	case *ast.CallExpression:
+		calls[node.Function.String()] = node.Tail
+		for _, arg := range node.Arguments {
+			collectCalls(arg, calls)
+		}
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
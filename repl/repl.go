@@ -6,16 +6,35 @@ import (
 	"io"
 	"monkey/compiler"
 	"monkey/lexer"
+	"monkey/object"
 	"monkey/parser"
 	"monkey/vm"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
+// Start runs the REPL. host grants the scripts access to files, arguments
+// and the environment; puts always writes to out. opts configure the
+// compiler for every line.
+func Start(in io.Reader, out io.Writer, host *object.Host, opts ...compiler.Option) {
+	if host == nil {
+		host = &object.Host{}
+	}
+	session := *host
+	session.Stdout = out
+
 	scanner := bufio.NewScanner(in)
 	//env := object.NewEnvironment()
 
+	// 입력 줄마다 새로 컴파일하더라도 이전 줄의 let 바인딩을 사용할 수 있게 상태를 유지한다.
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	random := object.NewRandom()
+
 	for {
 		fmt.Fprintf(out, PROMPT)
 		scanned := scanner.Scan()
@@ -40,14 +59,20 @@ func Start(in io.Reader, out io.Writer) {
 		//	io.WriteString(out, "\n")
 		//}
 
-		comp := compiler.New()
+		comp := compiler.NewWithState(symbolTable, constants, opts...)
 		err := comp.Compile(program)
 		if err != nil {
 			fmt.Fprintf(out, "Compilation failed:\n %s\n", err)
 			continue
 		}
+		for _, warning := range comp.Warnings() {
+			fmt.Fprintf(out, "warning: %s\n", warning)
+		}
+
+		code := comp.Bytecode()
+		constants = code.Constants
 
-		machine := vm.New(comp.Bytecode())
+		machine := vm.NewWithGlobalsState(code, globals, vm.WithHost(&session), vm.WithRandom(random))
 		err = machine.Run()
 
 		if err != nil {
@@ -11,6 +11,13 @@ const (
 	INT    = "INT"    // 1343456
 	STRING = "STRING" // "foobar"
 
+	// Interpolated strings are split around their ${...} expressions:
+	// "a ${x} b ${y} c" lexes as INTERP_HEAD("a "), x, INTERP_MIDDLE(" b "),
+	// y, INTERP_TAIL(" c").
+	INTERP_HEAD   = "INTERP_HEAD"
+	INTERP_MIDDLE = "INTERP_MIDDLE"
+	INTERP_TAIL   = "INTERP_TAIL"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -25,6 +32,9 @@ const (
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	COALESCE     = "??"
+	OPT_LBRACKET = "?["
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -45,11 +55,14 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	NULL     = "NULL"
 )
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1-based line of the first character
+	Column  int // 1-based column of the first character
 }
 
 var keywords = map[string]TokenType{
@@ -60,6 +73,7 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"null":   NULL,
 }
 
 func LookupIdent(ident string) TokenType {
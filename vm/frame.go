@@ -0,0 +1,24 @@
+package vm
+
+import (
+	"monkey/code"
+	"monkey/object"
+)
+
+/*
+Frame - 호출 중인 함수 하나의 실행 상태.
+basePointer는 호출 직전의 stackPointer로, 이 위치부터 함수의 지역 바인딩이 저장된다.
+*/
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
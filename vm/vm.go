@@ -1,55 +1,336 @@
 package vm
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"monkey/code"
 	"monkey/compiler"
 	"monkey/object"
 )
 
+// 기본 스택 크기. WithStackSize로 바꿀 수 있다.
 const StackSize = 2048
 
-var True = &object.Boolean{Value: true}
-var False = &object.Boolean{Value: false}
+// 기본 최대 호출 깊이. 스택의 최대 크기를 StackSize보다 크게 하면 그에 비례해 함께 늘어난다.
+const MaxFrames = 1024
+
+// OpGetGlobal, OpSetGlobal의 피연산자 너비(2바이트)로 표현할 수 있는 전역 바인딩의 최대 개수
+const GlobalsSize = 65536
+
+// 평가기와 같은 싱글턴 값을 사용해서 내장 함수가 돌려준 값도 같은 방식으로 비교할 수 있게 한다.
+var True = object.TRUE
+var False = object.FALSE
+var Null = object.NULL
+
+// 잘못 컴파일된 프로그램이 스택에 없는 값을 꺼내려 할 때 반환한다.
+var errStackUnderflow = errors.New("stack underflow")
+
+// 값 스택이 최대 크기를 넘을 때 반환한다.
+var errStackOverflow = errors.New("stack overflow")
+
+// 함수 호출이 최대 호출 깊이를 넘을 때 반환한다.
+var errFrameOverflow = errors.New("call stack overflow: too many nested calls")
 
 type VM struct {
-	constants    []object.Object
-	instructions code.Instructions
-	stack        []object.Object
+	constants []object.Object
+	stack     []object.Object
+	// 스택이 커질 수 있는 최대 크기. len(stack)과 같으면 커지지 않는다.
+	maxStackSize int
 	// 새로운 요소 저장 시 stack[stackPointer]에 저장하고 값을 1 증가시킴
 	stackPointer int
+	// let으로 바인딩된 값. 인덱스는 컴파일러의 SymbolTable이 정한다.
+	globals []object.Object
+
+	// 호출 중인 함수의 프레임. frames[0]은 프로그램 전체(main)를 실행하는 프레임이다.
+	frames      []*Frame
+	framesIndex int
+	// 프레임이 늘어날 수 있는 최대 개수(호출 깊이)
+	maxFrames int
+
+	// 스크립트가 접근할 수 있는 입출력. nil이면 puts만 os.Stdout에 출력할 수 있다.
+	host *object.Host
+	// random 내장 함수가 사용하는 난수 생성기
+	random *rand.Rand
+
+	// 실행할 수 있는 명령어 개수와 할당할 수 있는 총 바이트. 0이면 제한하지 않는다.
+	budget object.Budget
+	// RunContext, CallContext 실행 중의 제한. nil이면 명령어와 할당을 세지 않는다.
+	limits *object.Limits
+	// 마지막 RunContext, CallContext가 할당한 총 바이트
+	allocated int64
+}
+
+/*
+Option - New에 넘겨 VM의 설정을 바꾼다.
+*/
+type Option func(*VM)
+
+/*
+WithHost - 스크립트가 사용할 수 있는 입출력과 파일 경로를 허용한다.
+*/
+func WithHost(host *object.Host) Option {
+	return func(vm *VM) {
+		vm.host = host
+	}
+}
+
+/*
+WithRandom - random 내장 함수가 사용할 난수 생성기. REPL처럼 여러 VM이 이어서 실행될 때 seed의 효과를 유지하기 위함
+기본값은 VM마다 새로 만든 object.NewRandom()이다.
+*/
+func WithRandom(random *rand.Rand) Option {
+	return func(vm *VM) {
+		vm.random = random
+	}
+}
+
+/*
+WithStepLimit - RunContext, CallContext가 실행할 수 있는 명령어 개수를 제한한다.
+초과하면 object.ErrStepLimitExceeded를 반환한다.
+*/
+func WithStepLimit(maxSteps int64) Option {
+	return func(vm *VM) {
+		vm.budget.MaxSteps = maxSteps
+	}
 }
 
-func New(bytecode *compiler.Bytecode) *VM {
-	return &VM{
-		instructions: bytecode.Instructions,
+/*
+WithAllocationLimit - RunContext, CallContext가 할당할 수 있는 배열, 문자열, 해시의 크기(바이트)의 합을 제한한다.
+쓰레기가 된 값도 빼지 않으므로 사용 중인 메모리가 아니라 할당한 양의 제한이다. 초과하면 object.ErrAllocationLimitExceeded를 반환한다.
+*/
+func WithAllocationLimit(maxBytes int64) Option {
+	return func(vm *VM) {
+		vm.budget.MaxAllocated = maxBytes
+	}
+}
+
+/*
+WithStackSize - 스택의 크기를 정한다. 기본값은 StackSize다.
+*/
+func WithStackSize(size int) Option {
+	return func(vm *VM) {
+		vm.stack = make([]object.Object, size)
+	}
+}
+
+/*
+WithGrowableStack - 스택이 가득 차면 maxSize까지 두 배씩 늘린다.
+처음에는 작은 스택으로 시작하고 깊은 재귀가 필요할 때만 메모리를 쓰게 할 수 있다.
+최대 호출 깊이도 기본값(StackSize에 MaxFrames)과 같은 비율로 maxSize에 맞춰 늘어난다.
+프레임 배열도 스택처럼 필요할 때 두 배씩 늘린다.
+*/
+func WithGrowableStack(maxSize int) Option {
+	return func(vm *VM) {
+		vm.maxStackSize = maxSize
+	}
+}
+
+/*
+WithMaxFrames - 최대 호출 깊이(main 프레임 포함)를 정한다. 기본값은 스택의 최대 크기에서 정해진다.
+지역 바인딩이 많은 함수는 이 깊이에 닿기 전에 스택이 가득 찰 수 있다.
+*/
+func WithMaxFrames(maxFrames int) Option {
+	return func(vm *VM) {
+		vm.maxFrames = maxFrames
+	}
+}
+
+func New(bytecode *compiler.Bytecode, opts ...Option) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	vm := &VM{
 		constants:    bytecode.Constants,
 		stack:        make([]object.Object, StackSize),
 		stackPointer: 0,
+		globals:      make([]object.Object, GlobalsSize),
+		frames:       frames,
+		framesIndex:  1,
+		random:       object.NewRandom(),
+	}
+
+	for _, opt := range opts {
+		opt(vm)
 	}
+
+	vm.maxStackSize = max(vm.maxStackSize, len(vm.stack))
+	if vm.maxFrames <= 0 {
+		vm.maxFrames = max(MaxFrames, vm.maxStackSize/(StackSize/MaxFrames))
+	}
+
+	return vm
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+/*
+pushFrame - 프레임 배열이 가득 차면 maxFrames까지 두 배씩 늘린다.
+*/
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= vm.maxFrames {
+		return errFrameOverflow
+	}
+	if vm.framesIndex >= len(vm.frames) {
+		frames := make([]*Frame, min(2*len(vm.frames), vm.maxFrames))
+		copy(frames, vm.frames)
+		vm.frames = frames
+	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+/*
+NewWithGlobalsState - REPL에서 이전 입력의 전역 바인딩을 유지하기 위함
+*/
+func NewWithGlobalsState(bytecode *compiler.Bytecode, s []object.Object, opts ...Option) *VM {
+	vm := New(bytecode, opts...)
+	vm.globals = s
+	return vm
 }
 
 /*
 Run - 인출 - 복호화 - 실행 주기가 loop로 동작함
 */
 func (vm *VM) Run() error {
-	// {"1 + 2"}가 들어왔을 경우
-	// fmt.Printf("gerere %s", vm.constants)    // -> [object.Integer(1), object.Integer(2)]
-	// fmt.Printf("gerere %s", vm.instructions) // -> 0000 OpConstant 0, 0003 OpConstant 1
-	for instructionPointer := 0; instructionPointer < len(vm.instructions); instructionPointer++ {
-		op := code.Opcode(vm.instructions[instructionPointer])
+	return vm.run(0)
+}
+
+/*
+RunContext - Run과 같지만 ctx가 끝나면 object.ErrCanceled를, WithStepLimit의 개수를 넘기면
+object.ErrStepLimitExceeded를 반환한다.
+*/
+func (vm *VM) RunContext(ctx context.Context) error {
+	return vm.withLimits(ctx, func() error {
+		return vm.run(0)
+	})
+}
+
+/*
+CallContext - Call과 같지만 RunContext와 같은 제한을 두고, 실패를 error로 반환한다.
+*/
+func (vm *VM) CallContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	var result object.Object
+	err := vm.withLimits(ctx, func() error {
+		result = vm.Call(fn, args...)
+		if errObj, ok := result.(*object.Error); ok {
+			return errors.New(errObj.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+/*
+withLimits - 제한이 걸린 동안 실행한다. 콜백 안에서 제한에 걸려 오류 메시지로만 전달되더라도
+errors.Is로 구분할 수 있게 제한의 오류를 그대로 반환한다.
+*/
+func (vm *VM) withLimits(ctx context.Context, run func() error) error {
+	limits := object.NewLimits(ctx, vm.budget)
+	if limits != nil {
+		if err := limits.Check(); err != nil {
+			return err
+		}
+	}
+
+	outer := vm.limits
+	vm.limits = limits
+	defer func() { vm.limits = outer }()
+
+	err := run()
+	if limits == nil {
+		return err
+	}
+
+	vm.allocated = limits.Allocated()
+	if limits.Err() != nil {
+		return limits.Err()
+	}
+	return err
+}
+
+/*
+Allocated - 마지막 RunContext, CallContext가 할당한 배열, 문자열, 해시의 크기(바이트).
+값이 언제 필요 없어지는지는 추적하지 않으므로 사용 중인 메모리가 아니라 할당한 양의 합이다.
+제한이 없었다면 세지 않으므로 0이다.
+*/
+func (vm *VM) Allocated() int64 {
+	return vm.allocated
+}
+
+/*
+Limits - 내장 함수가 새로 만든 값을 할당 제한에 반영하고, 큰 값을 할당하기 전에 제한을 확인할 수 있게 한다.
+*/
+func (vm *VM) Limits() *object.Limits {
+	return vm.limits
+}
+
+/*
+pushAllocated - 새로 할당한 배열, 문자열, 해시를 할당 제한에 반영하고 스택에 넣는다.
+*/
+func (vm *VM) pushAllocated(obj object.Object) error {
+	if vm.limits != nil {
+		if err := vm.limits.Alloc(obj); err != nil {
+			return err
+		}
+	}
+	return vm.push(obj)
+}
+
+/*
+run - 프레임이 stopDepth개보다 많이 남아있는 동안 실행한다.
+Run은 main 프레임이 끝날 때까지, Call은 호출한 함수가 반환될 때까지 실행한다.
+*/
+func (vm *VM) run(stopDepth int) error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.framesIndex > stopDepth && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		if vm.limits != nil {
+			if err := vm.limits.Step(); err != nil {
+				return err
+			}
+		}
+
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
 
 		switch op {
 		case code.OpConstant:
 			// opcode 다음부터 읽음
-			constIndex := code.ReadUnit16(vm.instructions[instructionPointer+1:])
+			constIndex := code.ReadUnit16(ins[ip+1:])
 			// OpConstant의 operandWidth는 2임
-			instructionPointer += 2
+			vm.currentFrame().ip += 2
 			err := vm.push(vm.constants[constIndex])
 			if err != nil {
 				return err
 			}
 		case code.OpPop:
+			if err := vm.require(1); err != nil {
+				return err
+			}
 			vm.pop()
 		case code.OpAdd, code.OpSub, code.OpDiv, code.OpMul:
 			err := vm.executeBinaryOperation(op)
@@ -74,21 +355,447 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUnit16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.require(1); err != nil {
+				return err
+			}
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUnit16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err := vm.push(vm.globals[globalIndex])
+			if err != nil {
+				return err
+			}
+		case code.OpMinus:
+			err := vm.executeMinusOperator()
+			if err != nil {
+				return err
+			}
+		case code.OpBang:
+			err := vm.executeBangOperator()
+			if err != nil {
+				return err
+			}
+		case code.OpJump:
+			position := int(code.ReadUnit16(ins[ip+1:]))
+			// 반복문이 ip를 1 증가시키므로 목적지 바로 앞을 가리키게 한다.
+			vm.currentFrame().ip = position - 1
+		case code.OpJumpNotTruthy:
+			position := int(code.ReadUnit16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if err := vm.require(1); err != nil {
+				return err
+			}
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = position - 1
+			}
+		case code.OpNull:
+			err := vm.push(Null)
+			if err != nil {
+				return err
+			}
+		case code.OpJumpNotNull:
+			position := int(code.ReadUnit16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if err := vm.require(1); err != nil {
+				return err
+			}
+
+			// null이 아니면 왼쪽 값이 결과이므로 스택에 그대로 남겨둔다.
+			if vm.stack[vm.stackPointer-1] != Null {
+				vm.currentFrame().ip = position - 1
+			} else {
+				vm.pop()
+			}
+		case code.OpArray:
+			numElements := int(code.ReadUnit16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if err := vm.require(numElements); err != nil {
+				return err
+			}
+			array := vm.buildArray(vm.stackPointer-numElements, vm.stackPointer)
+			vm.stackPointer = vm.stackPointer - numElements
+
+			err := vm.pushAllocated(array)
+			if err != nil {
+				return err
+			}
+		case code.OpHash:
+			numElements := int(code.ReadUnit16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if err := vm.require(numElements); err != nil {
+				return err
+			}
+			hash, err := vm.buildHash(vm.stackPointer-numElements, vm.stackPointer)
+			if err != nil {
+				return err
+			}
+			vm.stackPointer = vm.stackPointer - numElements
+
+			err = vm.pushAllocated(hash)
+			if err != nil {
+				return err
+			}
+		case code.OpIndex, code.OpSafeIndex:
+			if err := vm.require(2); err != nil {
+				return err
+			}
+			index := vm.pop()
+			left := vm.pop()
+
+			if op == code.OpSafeIndex && left == Null {
+				err := vm.push(Null)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			err := vm.executeIndexExpression(left, index)
+			if err != nil {
+				return err
+			}
+		case code.OpSlice, code.OpSafeSlice:
+			if err := vm.require(3); err != nil {
+				return err
+			}
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+
+			if op == code.OpSafeSlice && left == Null {
+				err := vm.push(Null)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			result, err := object.Slice(left, start, end)
+			if err != nil {
+				return err
+			}
+
+			err = vm.pushAllocated(result)
+			if err != nil {
+				return err
+			}
+		case code.OpInterpolate:
+			numParts := int(code.ReadUnit16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if err := vm.require(numParts); err != nil {
+				return err
+			}
+			str := vm.buildString(vm.stackPointer-numParts, vm.stackPointer)
+			vm.stackPointer = vm.stackPointer - numParts
+
+			err := vm.pushAllocated(str)
+			if err != nil {
+				return err
+			}
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err := vm.executeCall(int(numArgs))
+			if err != nil {
+				return err
+			}
+		case code.OpTailCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err := vm.executeTailCall(int(numArgs))
+			if err != nil {
+				return err
+			}
+		case code.OpReturnValue:
+			if err := vm.require(1); err != nil {
+				return err
+			}
+			returnValue := vm.pop()
+
+			// main 프레임에서의 return은 프로그램을 끝낸다. 반환값은 마지막으로 꺼낸 값으로 남는다.
+			if vm.framesIndex == 1 {
+				vm.currentFrame().ip = len(ins) - 1
+				continue
+			}
+
+			frame := vm.popFrame()
+			// 호출된 함수 자신도 스택에서 제거하기 위해 basePointer보다 하나 아래로 내린다.
+			vm.stackPointer = frame.basePointer - 1
+
+			err := vm.push(returnValue)
+			if err != nil {
+				return err
+			}
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.stackPointer = frame.basePointer - 1
+
+			err := vm.push(Null)
+			if err != nil {
+				return err
+			}
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.require(1); err != nil {
+				return err
+			}
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			err := vm.push(vm.stack[frame.basePointer+int(localIndex)])
+			if err != nil {
+				return err
+			}
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			definition := object.Builtins[builtinIndex]
+			err := vm.push(definition.Builtin)
+			if err != nil {
+				return err
+			}
+		case code.OpClosure:
+			constIndex := code.ReadUnit16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err := vm.pushClosure(int(constIndex), int(numFree))
+			if err != nil {
+				return err
+			}
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			err := vm.push(currentClosure.Free[freeIndex])
+			if err != nil {
+				return err
+			}
+		case code.OpCurrentClosure:
+			err := vm.push(vm.currentFrame().cl)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+Call - 내장 함수가 Monkey 함수를 호출할 수 있게 한다. (object.Runtime 구현)
+함수와 인자를 스택에 올려 OpCall과 같은 방식으로 호출한 뒤, 그 함수가 반환될 때까지만 실행한다.
+실패하면 스택과 프레임을 호출 전 상태로 되돌리고 *object.Error를 반환한다.
+*/
+func (vm *VM) Call(fn object.Object, args ...object.Object) object.Object {
+	framesIndex, stackPointer := vm.framesIndex, vm.stackPointer
+
+	err := vm.callFunction(fn, args)
+	if err != nil {
+		vm.framesIndex, vm.stackPointer = framesIndex, stackPointer
+		return &object.Error{Message: err.Error()}
+	}
+
+	return vm.pop()
+}
+
+/*
+Host - object.Runtime 구현. 내장 함수가 허용된 입출력을 확인할 때 사용한다.
+*/
+func (vm *VM) Host() *object.Host {
+	return vm.host
+}
+
+/*
+Random - 내장 함수 random, seed가 사용하는 이 VM의 난수 생성기
+*/
+func (vm *VM) Random() *rand.Rand {
+	return vm.random
+}
+
+func (vm *VM) callFunction(fn object.Object, args []object.Object) error {
+	err := vm.push(fn)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range args {
+		err := vm.push(arg)
+		if err != nil {
+			return err
 		}
 	}
 
+	framesIndex := vm.framesIndex
+
+	err = vm.executeCall(len(args))
+	if err != nil {
+		return err
+	}
+
+	return vm.run(framesIndex)
+}
+
+/*
+executeCall - 스택에는 호출할 함수와 numArgs개의 인자가 순서대로 쌓여있다.
+*/
+func (vm *VM) executeCall(numArgs int) error {
+	if err := vm.require(numArgs + 1); err != nil {
+		return err
+	}
+
+	callee := vm.stack[vm.stackPointer-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+/*
+callClosure - 인자는 이미 스택에 있으므로 그 자리가 그대로 함수의 첫 지역 바인딩이 된다.
+지역 바인딩을 위한 자리만큼 stackPointer를 올려둔다.
+*/
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+	}
+
+	frame := NewFrame(cl, vm.stackPointer-numArgs)
+	err := vm.ensureStack(frame.basePointer + cl.Fn.NumLocals)
+	if err != nil {
+		return err
+	}
+
+	err = vm.pushFrame(frame)
+	if err != nil {
+		return err
+	}
+
+	vm.stackPointer = frame.basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+/*
+executeTailCall - 꼬리 호출된 클로저는 새 프레임을 쌓지 않고 현재 프레임의 자리를 물려받는다.
+함수와 인자를 현재 함수가 있던 자리로 내려 옮긴 뒤 프레임을 바꿔 끼우므로, 꼬리 재귀가 아무리 깊어도
+프레임과 스택은 자라지 않는다. 호출된 함수가 반환하면 값은 현재 함수를 호출한 쪽으로 바로 돌아간다.
+내장 함수는 프레임을 쓰지 않으므로 OpCall과 똑같이 호출한다.
+*/
+func (vm *VM) executeTailCall(numArgs int) error {
+	if err := vm.require(numArgs + 1); err != nil {
+		return err
+	}
+
+	cl, ok := vm.stack[vm.stackPointer-1-numArgs].(*object.Closure)
+	if !ok || vm.framesIndex == 1 {
+		return vm.executeCall(numArgs)
+	}
+	if numArgs != cl.Fn.NumParameters {
+		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+	}
+
+	basePointer := vm.currentFrame().basePointer
+	copy(vm.stack[basePointer-1:], vm.stack[vm.stackPointer-1-numArgs:vm.stackPointer])
+
+	err := vm.ensureStack(basePointer + cl.Fn.NumLocals)
+	if err != nil {
+		return err
+	}
+
+	vm.frames[vm.framesIndex-1] = NewFrame(cl, basePointer)
+	vm.stackPointer = basePointer + cl.Fn.NumLocals
+
 	return nil
 }
 
+/*
+callBuiltin - 내장 함수가 반환한 *object.Error는 평가기처럼 실행을 중단시키는 에러로 다룬다.
+*/
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.stackPointer-numArgs : vm.stackPointer]
+
+	result := builtin.Fn(vm, args...)
+	vm.stackPointer = vm.stackPointer - numArgs - 1
+
+	if errObj, ok := result.(*object.Error); ok {
+		return errors.New(errObj.Message)
+	}
+	if result == nil {
+		result = Null
+	}
+
+	// 내장 함수는 새로 만든 값만 스스로 할당 제한에 반영한다.
+	return vm.push(result)
+}
+
+/*
+pushClosure - 자유 변수는 OpClosure 직전에 스택에 올라와 있다.
+*/
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	if err := vm.require(numFree); err != nil {
+		return err
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.stackPointer-numFree+i]
+	}
+	vm.stackPointer = vm.stackPointer - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
+
 func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	if err := vm.require(2); err != nil {
+		return err
+	}
+
 	right := vm.pop()
 	left := vm.pop()
 
 	leftType := left.Type()
 	rightType := right.Type()
 
-	if leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ {
+	switch {
+	case leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ:
 		return vm.executeBinaryIntegerOperation(op, left, right)
+	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
+		return vm.executeBinaryStringOperation(op, left, right)
 	}
 
 	return fmt.Errorf("unsupported types for binary operation: %s %s", leftType, rightType)
@@ -110,13 +817,42 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.O
 	case code.OpDiv:
 		result = leftValue / rightValue
 	default:
-		fmt.Errorf("unknown Integer operator: %d", op)
+		return fmt.Errorf("unknown Integer operator: %d", op)
 	}
 
 	return vm.push(&object.Integer{Value: result})
 }
 
+func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Object) error {
+	if op != code.OpAdd {
+		return fmt.Errorf("unknown string operator: %d", op)
+	}
+
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	return vm.pushAllocated(&object.String{Value: leftValue + rightValue})
+}
+
+/*
+buildString - stack[startIndex:endIndex]의 값을 Inspect()한 결과를 순서대로 이어 붙인다.
+보간 문자열 안의 문자열 조각은 Inspect()가 값 그대로를 반환하므로 그대로 이어진다.
+*/
+func (vm *VM) buildString(startIndex, endIndex int) object.Object {
+	var out bytes.Buffer
+
+	for i := startIndex; i < endIndex; i++ {
+		out.WriteString(vm.stack[i].Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func (vm *VM) executeComparison(op code.Opcode) error {
+	if err := vm.require(2); err != nil {
+		return err
+	}
+
 	right := vm.pop()
 	left := vm.pop()
 
@@ -124,11 +860,12 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 		return vm.executeBinaryIntegerComparison(op, left, right)
 	}
 
+	// 정수 이외의 값은 object.Equals로 구조적으로 비교한다. (같은 내용의 문자열, 배열, 해시는 같다)
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(right == left))
+		return vm.push(nativeBoolToBooleanObject(object.Equals(left, right)))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(right != left))
+		return vm.push(nativeBoolToBooleanObject(!object.Equals(left, right)))
 	default:
 		return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
 	}
@@ -146,10 +883,137 @@ func (vm *VM) executeBinaryIntegerComparison(op code.Opcode, left, right object.
 	case code.OpGreaterThan:
 		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
 	default:
-		fmt.Errorf("unkown operator: %d", op)
+		return fmt.Errorf("unkown operator: %d", op)
 	}
+}
 
-	return nil
+func (vm *VM) executeMinusOperator() error {
+	if err := vm.require(1); err != nil {
+		return err
+	}
+
+	operand := vm.pop()
+
+	if operand.Type() != object.INTEGER_OBJ {
+		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
+	}
+
+	value := operand.(*object.Integer).Value
+	return vm.push(&object.Integer{Value: -value})
+}
+
+func (vm *VM) executeBangOperator() error {
+	if err := vm.require(1); err != nil {
+		return err
+	}
+
+	operand := vm.pop()
+
+	switch operand {
+	case True:
+		return vm.push(False)
+	case False:
+		return vm.push(True)
+	case Null:
+		return vm.push(True)
+	default:
+		return vm.push(False)
+	}
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+/*
+buildHash - 스택에는 키, 값, 키, 값... 순서로 쌓여있다.
+*/
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeStringIndex(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return vm.executeHashIndex(left, index)
+	default:
+		return fmt.Errorf("index operator not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) error {
+	arrayObject := array.(*object.Array)
+	i := index.(*object.Integer).Value
+
+	element, ok := arrayObject.At(i)
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(element)
+}
+
+func (vm *VM) executeStringIndex(str, index object.Object) error {
+	stringObject := str.(*object.String)
+	i := index.(*object.Integer).Value
+
+	char, ok := stringObject.At(i)
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(char)
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", index.Type())
+	}
+
+	value, ok := hashObject.Get(key)
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(value)
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	default:
+		return true
+	}
 }
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
@@ -161,8 +1025,11 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 }
 
 func (vm *VM) push(o object.Object) error {
-	if vm.stackPointer >= StackSize {
-		return fmt.Errorf("stack overflow")
+	if vm.stackPointer >= len(vm.stack) {
+		err := vm.ensureStack(vm.stackPointer + 1)
+		if err != nil {
+			return err
+		}
 	}
 
 	vm.stack[vm.stackPointer] = o
@@ -171,6 +1038,37 @@ func (vm *VM) push(o object.Object) error {
 	return nil
 }
 
+/*
+ensureStack - 스택에 size개의 값이 들어갈 수 있게 한다. 커질 수 있는 스택이면 두 배씩 늘린다.
+내장 함수에 넘긴 인자는 이전 스택을 가리키지만, 콜백이 그 아래 값을 바꾸지 않으므로 그대로 읽을 수 있다.
+*/
+func (vm *VM) ensureStack(size int) error {
+	if size <= len(vm.stack) {
+		return nil
+	}
+	if size > vm.maxStackSize {
+		return errStackOverflow
+	}
+
+	stack := make([]object.Object, min(max(2*len(vm.stack), size), vm.maxStackSize))
+	copy(stack, vm.stack[:vm.stackPointer])
+	vm.stack = stack
+
+	return nil
+}
+
+/*
+require - 현재 프레임이 스택에 올린 값이 n개 이상인지 확인한다. pop하기 전에 호출한다.
+프레임의 지역 바인딩과 호출한 쪽의 값은 꺼낼 수 없도록 basePointer + 지역 바인딩 수를 바닥으로 본다.
+*/
+func (vm *VM) require(n int) error {
+	frame := vm.currentFrame()
+	if vm.stackPointer-n < frame.basePointer+frame.cl.Fn.NumLocals {
+		return errStackUnderflow
+	}
+	return nil
+}
+
 func (vm *VM) pop() object.Object {
 	topOfStack := vm.stack[vm.stackPointer-1]
 	vm.stackPointer--
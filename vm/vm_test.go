@@ -1,13 +1,18 @@
 package vm
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"monkey/ast"
+	"monkey/code"
 	"monkey/compiler"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
 	"testing"
+	"time"
 )
 
 type vmTestCase struct {
@@ -44,6 +49,9 @@ func TestIntegerArithmetic(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"-5", -5},
+		{"-50 + 100 + -50", 0},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
 	}
 
 	runVmTests(t, tests)
@@ -53,34 +61,498 @@ func TestBooleanExpressions(t *testing.T) {
 	tests := []vmTestCase{
 		{"true", true},
 		{"false", false},
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!5", true},
+		{"!(if (false) { 5; })", true},
 	}
 
 	runVmTests(t, tests)
 }
 
-func runVmTests(t *testing.T, tests []vmTestCase) {
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = 2; one + two", 3},
+		{"let one = 1; let two = one + one; one + two", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`"monkey"`, "monkey"},
+		{`"mon" + "key"`, "monkey"},
+		{`"mon" + "key" + "banana"`, "monkeybanana"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []vmTestCase{
+		{`let a = 1; let b = 2; "total: ${a + b}"`, "total: 3"},
+		{`"${true} and ${1 > 2}"`, "true and false"},
+		{`let name = "몽키"; "안녕, ${name}!"`, "안녕, 몽키!"},
+		{`"${"nested ${1 + 1}"}"`, "nested 2"},
+		{`"${1}${2}${3}"`, "123"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (true) { 10 } else { 20 }", 10},
+		{"if (false) { 10 } else { 20 } ", 20},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 > 2) { 10 }", Null},
+		{"if (false) { 10 }", Null},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) { }", Null},
+		{"if (true) { let a = 1; }", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestNullAndOptionalChaining(t *testing.T) {
+	tests := []vmTestCase{
+		{"null", Null},
+		{"!null", true},
+		{"null == null", true},
+		{"null ?? 5", 5},
+		{"1 ?? 5", 1},
+		{"false ?? 5", false},
+		{"null ?? null", Null},
+		{"null ?? null ?? 3", 3},
+		{`let h = {"a": {"b": 1}}; h?["a"]?["b"]`, 1},
+		{`let h = {"a": {"b": 1}}; h?["x"]?["b"]`, Null},
+		{`let h = {"a": {"b": 1}}; h?["x"]?["b"] ?? 42`, 42},
+		{`null?[0]`, Null},
+		{`[1, 2]?[1]`, 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"[]", []int{}},
+		{"[1, 2, 3]", []int{1, 2, 3}},
+		{"[1 + 2, 3 * 4, 5 + 6]", []int{3, 12, 11}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestHashLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			"{}", map[object.HashKey]int64{},
+		},
+		{
+			"{1: 2, 2: 3}",
+			map[object.HashKey]int64{
+				(&object.Integer{Value: 1}).HashKey(): 2,
+				(&object.Integer{Value: 2}).HashKey(): 3,
+			},
+		},
+		{
+			"{1 + 1: 2 * 2, 3 + 3: 4 * 4}",
+			map[object.HashKey]int64{
+				(&object.Integer{Value: 2}).HashKey(): 4,
+				(&object.Integer{Value: 6}).HashKey(): 16,
+			},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, "c": 3}`, "{b: 1, a: 2, c: 3}"},
+		{`{3: "x", 1: "y", 2: "z"}`, "{3: x, 1: y, 2: z}"},
+		{`{"a": 1, "b": 2, "a": 3}`, "{a: 3, b: 2}"},
+		{`{true: 1, "k": {"z": 1, "y": 2}}`, "{true: 1, k: {z: 1, y: 2}}"},
+	}
+
 	for _, tt := range tests {
-		// 입력을 렉싱, 파싱하고 AST를 만든다
 		program := parse(tt.input)
 
-		// AST를 컴파일러에 전달한다.
 		comp := compiler.New()
 		err := comp.Compile(program)
-
 		if err != nil {
 			t.Fatalf("comp error: %s", err)
 		}
 
 		vm := New(comp.Bytecode())
 		err = vm.Run()
-
 		if err != nil {
 			t.Fatalf("vm error: %s", err)
 		}
 
-		stackElement := vm.LastPoppedStackElement()
+		actual := vm.LastPoppedStackElement().Inspect()
+		if actual != tt.expected {
+			t.Errorf("wrong Inspect() output. want=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []vmTestCase{
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{`[1, 2] == [1, 2]`, true},
+		{`[1, 2] != [1, 2]`, false},
+		{`[1, 2] == [2, 1]`, false},
+		{`[1, [2, "x"]] == [1, [2, "x"]]`, true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`1 == "1"`, false},
+		{`[] == {}`, false},
+		{`null == [null][0]`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestArrayHashKeys(t *testing.T) {
+	tests := []vmTestCase{
+		{`{[1, 2]: "a"}[[1, 2]]`, "a"},
+		{`{[1, 2]: "a"}[[2, 1]]`, Null},
+		{`{[1, [2]]: "a", [1, 2]: "b"}[[1, [2]]]`, "a"},
+		{`{[]: "empty"}[[]]`, "empty"},
+		{`let key = ["x", true]; {key: "a"}[["x", true]]`, "a"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][-2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:-1]", []int{1, 2, 3}},
+		{"[1, 2, 3, 4][-100:100]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][3:1]", []int{}},
+		{"let a = [1, 2, 3]; let i = 1; a[i:i + 1]", []int{2}},
+		{`"monkey"[1:3]`, "on"},
+		{`"monkey"[:-3]`, "mon"},
+		{`"안녕하세요"[1:3]`, "녕하"},
+		{`null?[1:2]`, Null},
+		{`[1, 2]?[1:]`, []int{2}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][0 + 2]", 3},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"[][0]", Null},
+		{"[1, 2, 3][99]", Null},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[1][-2]", Null},
+		{`"monkey"[0]`, "m"},
+		{`"monkey"[-1]`, "y"},
+		{`"한글"[1]`, "글"},
+		{`"monkey"[6]`, Null},
+		{"{1: 1, 2: 2}[1]", 1},
+		{"{1: 1, 2: 2}[2]", 2},
+		{"{1: 1}[0]", Null},
+		{"{}[0]", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`let fivePlusTen = fn() { 5 + 10; }; fivePlusTen();`, 15},
+		{`let one = fn() { 1; }; let two = fn() { 2; }; one() + two()`, 3},
+		{`let a = fn() { 1 }; let b = fn() { a() + 1 }; let c = fn() { b() + 1 }; c();`, 3},
+		{`let earlyExit = fn() { return 99; 100; }; earlyExit();`, 99},
+		{`let noReturn = fn() { }; noReturn();`, Null},
+		{`let returnsOne = fn() { 1; }; let returnsOneReturner = fn() { returnsOne; }; returnsOneReturner()();`, 1},
+		{`let identity = fn(a) { a; }; identity(4);`, 4},
+		{`let sum = fn(a, b) { let c = a + b; c; }; sum(1, 2) + sum(3, 4);`, 10},
+		{`let globalNum = 10; let sum = fn(a, b) { let c = a + b; c + globalNum; }; let outer = fn() { sum(1, 2) + sum(3, 4) + globalNum; }; outer() + globalNum;`, 50},
+		{`let x = if (true) { return 10; }; x`, 10},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{`let newClosure = fn(a) { fn() { a; }; }; let closure = newClosure(99); closure();`, 99},
+		{`let newAdder = fn(a, b) { fn(c) { a + b + c }; }; let adder = newAdder(1, 2); adder(8);`, 11},
+		{`let newAdderOuter = fn(a, b) { let c = a + b; fn(d) { let e = d + c; fn(f) { e + f; }; }; }; let newAdderInner = newAdderOuter(1, 2); let adder = newAdderInner(3); adder(8);`, 14},
+		{`let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1); } }; countDown(1);`, 0},
+		{`let wrapper = fn() { let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1); } }; countDown(1); }; wrapper();`, 0},
+		{`let fibonacci = fn(x) { if (x < 2) { return x; } fibonacci(x - 1) + fibonacci(x - 2) }; fibonacci(15);`, 610},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len([1, 2, 3])`, 3},
+		{`puts("hello")`, Null},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, Null},
+		{`last([1, 2, 3])`, 3},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`push([], 1)`, []int{1}},
+		{`let f = len; f("ab")`, 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`let n = 10; map([1, 2], fn(x) { x + n })`, "[11, 12]"},
+		{`map([[1], [1, 2]], len)`, "[1, 2]"},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3, 4]"},
+		{`reduce([1, 2, 3, 4], 0, fn(acc, x) { acc + x })`, "10"},
+		{`reduce([], 5, fn(acc, x) { acc + x })`, "5"},
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, "[3, 2, 1]"},
+		{`let a = [2, 1]; sort(a); a`, "[2, 1]"},
+		{`reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`reverse("한글")`, "글한"},
+		{`contains([1, [2]], [2])`, "true"},
+		{`contains([1, 2], 3)`, "false"},
+		{`index_of(["a", "b"], "b")`, "1"},
+		{`index_of([], 1)`, "-1"},
+		{`range(3)`, "[0, 1, 2]"},
+		{`range(1, 4)`, "[1, 2, 3]"},
+		{`range(10, 0, -3)`, "[10, 7, 4, 1]"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`keys({"a": 1, "b": 2})`, "[a, b]"},
+		{`values({"a": 1, "b": 2})`, "[1, 2]"},
+		{`has_key({"a": 1}, "a")`, "true"},
+		{`has_key({"a": 1}, "b")`, "false"},
+		{`delete({"a": 1, "b": 2}, "a")`, "{b: 2}"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, "{a: 1, b: 3, c: 4}"},
+		{`let double = fn(x) { x * 2 }; let apply = fn(f) { map([1, 2], f) }; apply(double)`, "[2, 4]"},
+		{`map([1, 2], fn(x) { reduce(range(x + 1), 0, fn(a, b) { a + b }) })`, "[1, 3]"},
+	}
+
+	for _, tt := range tests {
+		result, err := runVm(tt.input)
+		if err != nil {
+			t.Errorf("vm error for %s: %s", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. want=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`join(split("a,b,c", ","), "-")`, "a-b-c"},
+		{`trim("  hi ")`, "hi"},
+		{`upper("monkey")`, "MONKEY"},
+		{`replace("a-b", "-", "+")`, "a+b"},
+		{`starts_with("monkey", "mon")`, true},
+		{`contains("monkey", "nk")`, true},
+		{`find("한글 monkey", "monkey")`, 3},
+		{`repeat("ab", 2)`, "abab"},
+		{`chr(ord("a") + 1)`, "b"},
+		{`str(5) + "!"`, "5!"},
+		{`int("42") + 1`, 43},
+		{`map(["1", "2"], int)`, []int{1, 2}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`abs(-5)`, 5},
+		{`min(3, 1, 2)`, 1},
+		{`max([4, -2])`, 4},
+		{`pow(2, 10)`, 1024},
+		{`sqrt(17)`, 4},
+		{`clamp(5, 0, 3)`, 3},
+		{`gcd(12, 18)`, 6},
+		{`seed(7); let a = random(1000); seed(7); a == random(1000)`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTypeBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`type(1)`, "INTEGER"},
+		{`type(fn(x) { x })`, "FUNCTION"},
+		{`let x = 1; type(fn() { x })`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`is_function(fn() { 1 })`, true},
+		{`is_null(null)`, true},
+		{`to_int("42") + to_int(true)`, 43},
+		{`to_string(42)`, "42"},
+		{`to_bool(null)`, false},
+		{`len(to_array("ab"))`, 2},
+	}
+
+	runVmTests(t, tests)
+}
 
-		testExpectedObject(t, tt.expected, stackElement)
+func TestJSONBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`json_encode({"b": 1, "a": [true, null]})`, `{"b":1,"a":[true,null]}`},
+		{`json_encode([1], true)`, "[\n  1\n]"},
+		{`json_decode("[1, 2]")`, []int{1, 2}},
+		{`json_decode("{\"n\": 5}")["n"]`, 5},
+		{`let v = {"a": [1, {"b": null}]}; json_decode(json_encode(v)) == v`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fn() { 1; }(1);`, "wrong number of arguments: want=0, got=1"},
+		{`fn(a, b) { a + b; }(1);`, "wrong number of arguments: want=2, got=1"},
+		{`1()`, "not a function: INTEGER"},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`map([1], fn(x, y) { x })`, "wrong number of arguments: want=2, got=1"},
+		{`map([1, 2], fn(x) { -"a" })`, "unsupported type for negation: STRING"},
+		{`filter(1, len)`, "argument to `filter` must be ARRAY, got INTEGER"},
+		{`map([], 1)`, "callback of `map` must be a function, got INTEGER"},
+		{`sort([1, "a"])`, "cannot compare INTEGER and STRING in `sort`"},
+		{`sort([2, 1], fn(a, b) { a + "x" })`, "unsupported types for binary operation: INTEGER STRING"},
+		{`range(1, 2, 0)`, "step of `range` must not be zero"},
+		{`int("4x2")`, "could not parse \"4x2\" as integer"},
+		{`pow(2, -1)`, "exponent of `pow` must not be negative, got -1"},
+		{`to_int(fn() { 1 })`, "cannot convert FUNCTION to INTEGER"},
+		{`json_encode({"f": fn() { 1 }})`, "json_encode: cannot encode FUNCTION at $.f"},
+		{`has_key({}, {})`, "unusable as hash key: HASH"},
+	}
+
+	for _, tt := range tests {
+		_, err := runVm(tt.input)
+		if err == nil {
+			t.Errorf("expected VM error for %s but got none", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error for %s. want=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestCallbackErrorRestoresState(t *testing.T) {
+	program := parse(`let f = fn(x) { x + 1 }; f(1)`)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	f := vm.globals[0]
+	stackPointer := vm.stackPointer
+
+	result := vm.Call(f, &object.String{Value: "a"})
+	if _, ok := result.(*object.Error); !ok {
+		t.Fatalf("expected error, got=%T (%+v)", result, result)
+	}
+	if vm.stackPointer != stackPointer || vm.framesIndex != 1 {
+		t.Errorf("state not restored. stackPointer=%d, framesIndex=%d", vm.stackPointer, vm.framesIndex)
+	}
+
+	result = vm.Call(f, &object.Integer{Value: 41})
+	err = testIntegerObject(42, result)
+	if err != nil {
+		t.Errorf("testIntegerObject failed: %s", err)
+	}
+}
+
+func runVm(input string) (object.Object, error) {
+	comp := compiler.New()
+	err := comp.Compile(parse(input))
+	if err != nil {
+		return nil, err
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	return vm.LastPoppedStackElement(), nil
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	// 최적화한 바이트코드도 같은 결과를 내야 한다.
+	for _, opts := range [][]compiler.Option{nil, {compiler.WithOptimizations()}} {
+		for _, tt := range tests {
+			// 입력을 렉싱, 파싱하고 AST를 만든다
+			program := parse(tt.input)
+
+			// AST를 컴파일러에 전달한다.
+			comp := compiler.New(opts...)
+			err := comp.Compile(program)
+
+			if err != nil {
+				t.Fatalf("comp error: %s", err)
+			}
+
+			vm := New(comp.Bytecode())
+			err = vm.Run()
+
+			if err != nil {
+				t.Fatalf("vm error: %s", err)
+			}
+
+			stackElement := vm.LastPoppedStackElement()
+
+			testExpectedObject(t, tt.expected, stackElement)
+		}
 	}
 }
 
@@ -98,6 +570,57 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 		if err != nil {
 			t.Errorf("testBooleanObject failed: %s", err)
 		}
+	case string:
+		err := testStringObject(expected, actual)
+		if err != nil {
+			t.Errorf("testStringObject failed: %s", err)
+		}
+	case *object.Null:
+		if actual != Null {
+			t.Errorf("object is not Null: %T (%+v)", actual, actual)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object not Array: %T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+			return
+		}
+
+		for i, expectedElem := range expected {
+			err := testIntegerObject(int64(expectedElem), array.Elements[i])
+			if err != nil {
+				t.Errorf("testIntegerObject failed: %s", err)
+			}
+		}
+	case map[object.HashKey]int64:
+		hash, ok := actual.(*object.Hash)
+		if !ok {
+			t.Errorf("object is not Hash. got=%T (%+v)", actual, actual)
+			return
+		}
+
+		if hash.Len() != len(expected) {
+			t.Errorf("hash has wrong number of Pairs. want=%d, got=%d", len(expected), hash.Len())
+			return
+		}
+
+		for _, pair := range hash.Pairs() {
+			expectedValue, ok := expected[pair.Key.(object.Hashable).HashKey()]
+			if !ok {
+				t.Errorf("no expected value for key %s", pair.Key.Inspect())
+				continue
+			}
+
+			err := testIntegerObject(expectedValue, pair.Value)
+			if err != nil {
+				t.Errorf("testIntegerObject failed: %s", err)
+			}
+		}
 	}
 }
 
@@ -130,8 +653,311 @@ func testBooleanObject(expected bool, actual object.Object) error {
 	return nil
 }
 
+func testStringObject(expected string, actual object.Object) error {
+	result, ok := actual.(*object.String)
+
+	if !ok {
+		return fmt.Errorf("object is not String. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+	}
+
+	return nil
+}
+
 func parse(input string) *ast.Program {
 	l := lexer.New(input)
 	p := parser.New(l)
 	return p.ParseProgram()
 }
+
+func TestHost(t *testing.T) {
+	var out bytes.Buffer
+	host := &object.Host{Stdout: &out, Args: []string{"x"}}
+
+	comp := compiler.New()
+	err := comp.Compile(parse(`puts(map(args(), fn(a) { a + "!" }))`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode(), WithHost(host))
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if out.String() != "[x!]\n" {
+		t.Errorf("puts wrote %q", out.String())
+	}
+
+	_, err = runVm(`args()`)
+	if err == nil || err.Error() != "args: reading arguments is not permitted" {
+		t.Errorf("expected args to be denied without a host, got=%v", err)
+	}
+}
+
+func TestExecutionLimits(t *testing.T) {
+	countdown := `let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(500)`
+	// 콜백 안에서 오래 실행되므로 내장 함수를 거쳐도 제한 오류가 유지되는지 확인할 수 있다.
+	busy := `reduce(range(3000), 0, fn(acc, x) { reduce(range(3000), acc, fn(a, y) { a + 1 }) })`
+
+	newVM := func(input string, opts ...Option) *VM {
+		comp := compiler.New()
+		err := comp.Compile(parse(input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		return New(comp.Bytecode(), opts...)
+	}
+
+	err := newVM(countdown).RunContext(context.Background())
+	if err != nil {
+		t.Fatalf("unlimited run failed: %s", err)
+	}
+
+	err = newVM(countdown, WithStepLimit(100)).RunContext(context.Background())
+	if !errors.Is(err, object.ErrStepLimitExceeded) {
+		t.Errorf("expected step limit error, got=%v", err)
+	}
+
+	// Run은 제한을 두지 않는다.
+	err = newVM(countdown, WithStepLimit(100)).Run()
+	if err != nil {
+		t.Errorf("Run failed: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = newVM(countdown).RunContext(ctx)
+	if !errors.Is(err, object.ErrCanceled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error, got=%v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = newVM(busy).RunContext(ctx)
+	if !errors.Is(err, object.ErrCanceled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got=%v", err)
+	}
+
+	vm := newVM(`let f = fn(x) { map(range(x), fn(y) { y }) };`, WithStepLimit(1000))
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	_, err = vm.CallContext(context.Background(), vm.globals[0], &object.Integer{Value: 10000})
+	if !errors.Is(err, object.ErrStepLimitExceeded) {
+		t.Errorf("expected step limit error from CallContext, got=%v", err)
+	}
+	if vm.stackPointer != 0 || vm.framesIndex != 1 {
+		t.Errorf("state not restored. stackPointer=%d, framesIndex=%d", vm.stackPointer, vm.framesIndex)
+	}
+	result, err := vm.CallContext(context.Background(), vm.globals[0], &object.Integer{Value: 3})
+	if err != nil || result.Inspect() != "[0, 1, 2]" {
+		t.Errorf("CallContext after limit failed: %v, %v", result, err)
+	}
+}
+
+func TestAllocationLimits(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{`let grow = fn(arr, n) { if (n == 0) { arr } else { grow(push(arr, "x"), n - 1) } }; len(grow([], 500))`,
+			object.ErrAllocationLimitExceeded},
+		// 제한이 없다면 2^40 바이트의 문자열을 만든다.
+		{`let double = fn(s, n) { if (n == 0) { s } else { double(s + s, n - 1) } }; len(double("x", 40))`,
+			object.ErrAllocationLimitExceeded},
+		{`len("${[1, 2, 3]}" + repeat("x", 1000000000000))`, object.ErrAllocationLimitExceeded},
+		{`map(range(1000000000000000), fn(x) { x })`, object.ErrAllocationLimitExceeded},
+		{`let grow = fn(arr, n) { if (n == 0) { arr } else { grow(push(arr, "x"), n - 1) } }; len(grow([], 50))`,
+			nil},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode(), WithAllocationLimit(100000))
+		err = vm.RunContext(context.Background())
+		if !errors.Is(err, tt.err) {
+			t.Errorf("wrong error for %s. want=%v, got=%v", tt.input, tt.err, err)
+		}
+		if tt.err == nil && (vm.Allocated() == 0 || vm.Allocated() > 100000) {
+			t.Errorf("wrong allocation for %s: %d", tt.input, vm.Allocated())
+		}
+	}
+}
+
+func TestStackSize(t *testing.T) {
+	input := `
+	let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } };
+	let deep = fn(x) { sum(100) + x };
+	[sum(100), map([1, 2], deep)]
+	`
+	program := parse(input)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode(), WithStackSize(16))
+	err = vm.Run()
+	if err == nil || err.Error() != "stack overflow" {
+		t.Errorf("expected stack overflow, got=%v", err)
+	}
+
+	vm = New(comp.Bytecode(), WithStackSize(16), WithGrowableStack(4096))
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if vm.LastPoppedStackElement().Inspect() != "[5050, [5051, 5052]]" {
+		t.Errorf("wrong result: %s", vm.LastPoppedStackElement().Inspect())
+	}
+	if len(vm.stack) <= 16 || len(vm.stack) > 4096 {
+		t.Errorf("stack did not grow within its limit. len=%d", len(vm.stack))
+	}
+
+	vm = New(comp.Bytecode(), WithStackSize(16), WithGrowableStack(64))
+	err = vm.Run()
+	if err == nil || err.Error() != "stack overflow" {
+		t.Errorf("expected stack overflow past the maximum size, got=%v", err)
+	}
+
+	// 스택을 늘릴 수 있으면 호출 깊이도 MaxFrames를 넘어 늘어난다.
+	program = parse(`let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(2000)`)
+	comp = compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm = New(comp.Bytecode(), WithGrowableStack(1<<20))
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 2001000, vm.LastPoppedStackElement())
+
+	// 스택보다 호출 깊이가 먼저 가득 차면 다른 에러를 반환한다.
+	program = parse(`let f = fn() { f() + 1 }; f()`)
+	comp = compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	err = New(comp.Bytecode()).Run()
+	if err != errFrameOverflow {
+		t.Errorf("expected call stack overflow, got=%v", err)
+	}
+}
+
+func TestStackUnderflow(t *testing.T) {
+	concat := func(instructions ...[]byte) code.Instructions {
+		out := code.Instructions{}
+		for _, ins := range instructions {
+			out = append(out, ins...)
+		}
+		return out
+	}
+	one := []object.Object{&object.Integer{Value: 1}}
+	// 인자 하나를 지역 바인딩으로 갖고, 그보다 많은 값을 꺼내는 함수
+	fn := &object.CompiledFunction{
+		Instructions:  concat(code.Make(code.OpAdd), code.Make(code.OpReturnValue)),
+		NumLocals:     1,
+		NumParameters: 1,
+	}
+
+	tests := []*compiler.Bytecode{
+		{Instructions: code.Make(code.OpPop)},
+		{Instructions: concat(code.Make(code.OpConstant, 0), code.Make(code.OpAdd)), Constants: one},
+		{Instructions: concat(code.Make(code.OpArray, 3)), Constants: one},
+		{Instructions: concat(code.Make(code.OpCall, 0))},
+		{Instructions: concat(code.Make(code.OpSetGlobal, 0))},
+		{Instructions: concat(code.Make(code.OpConstant, 0), code.Make(code.OpSlice)), Constants: one},
+		{
+			Instructions: concat(
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpCall, 1),
+			),
+			Constants: []object.Object{one[0], fn},
+		},
+	}
+
+	for i, bytecode := range tests {
+		err := New(bytecode).Run()
+		if err == nil || err.Error() != "stack underflow" {
+			t.Errorf("test %d: expected stack underflow, got=%v", i, err)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input:    `let countdown = fn(n) { if (n == 0) { "done" } else { countdown(n - 1) } }; countdown(1000000)`,
+			expected: "done",
+		},
+		{
+			input:    `let sum = fn(n, acc) { if (n == 0) { return acc; } return sum(n - 1, acc + n); }; sum(100000, 0)`,
+			expected: 5000050000,
+		},
+		{
+			input: `
+			let odd = fn(n, even) { if (n == 0) { false } else { even(n - 1, odd) } };
+			let even = fn(n, odd) { if (n == 0) { true } else { odd(n - 1, even) } };
+			even(100001, odd)
+			`,
+			expected: false,
+		},
+		// 꼬리 위치의 내장 함수 호출과 콜백 안의 꼬리 호출
+		{
+			input:    `let loop = fn(n) { if (n == 0) { len("abc") } else { loop(n - 1) } }; map([3, 5000], loop)`,
+			expected: []int{3, 3},
+		},
+	}
+
+	runVmTests(t, tests)
+
+	// 꼬리 호출은 프레임도 스택도 늘리지 않는다.
+	program := parse(`let countdown = fn(n, a, b) { let c = a + b; if (n == 0) { c } else { countdown(n - 1, b, a) } }; countdown(1000000, 1, 2)`)
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := New(comp.Bytecode(), WithStackSize(32), WithGrowableStack(1<<20))
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 3, vm.LastPoppedStackElement())
+	if len(vm.stack) != 32 {
+		t.Errorf("stack grew during tail calls. len=%d", len(vm.stack))
+	}
+
+	// 꼬리 위치가 아닌 재귀 호출은 여전히 프레임을 쌓는다.
+	program = parse(`let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(1000000)`)
+	comp = compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	err := New(comp.Bytecode()).Run()
+	if err == nil || err.Error() != "stack overflow" {
+		t.Errorf("expected stack overflow, got=%v", err)
+	}
+
+	program = parse(`let f = fn(x) { x }; let g = fn() { f(1, 2) }; g()`)
+	comp = compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	err = New(comp.Bytecode()).Run()
+	if err == nil || err.Error() != "wrong number of arguments: want=1, got=2" {
+		t.Errorf("wrong error for a tail call with wrong arguments: %v", err)
+	}
+}